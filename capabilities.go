@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// moduleCommands 是各module依赖的四字命令，外层的每一项都是必需的，内层的命令任意一个可用即可
+// (srvr不可用时会使用stat)，没有列出的module使用原生协议，不依赖四字命令
+var moduleCommands = map[string][][]string{
+	"conf":        {{"conf"}},
+	"ruok":        {{"ruok"}},
+	"mntr":        {{"mntr"}},
+	"cons":        {{"cons"}},
+	"wchs":        {{"wchs"}},
+	"srvr":        {{"srvr", "stat"}},
+	"ensemble":    {{"conf"}, {"mntr"}},
+	"replication": {{"conf"}},
+	"session":     {{"conf"}},
+	"watch":       {{"conf"}},
+}
+
+// capabilityRetryInterval 探测时连接失败的host在这段时间内不再探测，避免每个module都等待一次超时。
+// 探测结果在capabilityTTL之后重新探测，修改4lw.commands.whitelist后不需要重启exporter
+const (
+	capabilityRetryInterval = 30 * time.Second
+	capabilityTTL           = 10 * time.Minute
+)
+
+// capability is the cached result of detecting one command
+type capability struct {
+	allowed  bool
+	detected time.Time
+}
+
+var (
+	capabilitiesMu sync.Mutex
+	capabilities   = make(map[string]map[string]capability)
+	unreachable    = make(map[string]time.Time)
+)
+
+// commandAllowed 探测host是否允许cmd并缓存capabilityTTL。连接失败时结果未知，
+// 返回true由module自己报告错误，并且在capabilityRetryInterval内跳过对该host的探测
+func commandAllowed(host string, cmd string) bool {
+	capabilitiesMu.Lock()
+	cached, ok := capabilities[host][cmd]
+	failed, down := unreachable[host]
+	capabilitiesMu.Unlock()
+	if ok && time.Since(cached.detected) < capabilityTTL {
+		return cached.allowed
+	}
+	if down && time.Since(failed) < capabilityRetryInterval {
+		return true
+	}
+
+	markUnreachable := func(err error) bool {
+		log.WithError(err).WithFields(log.Fields{"host": host, "command": cmd}).Debug("detecting command capability failed")
+		capabilitiesMu.Lock()
+		unreachable[host] = time.Now()
+		capabilitiesMu.Unlock()
+		return true
+	}
+
+	client, err := newClient(host)
+	if err != nil {
+		return markUnreachable(err)
+	}
+	defer client.Close()
+
+	reply, err := sendZookeeperCmd(client, host, cmd)
+	if err != nil {
+		return markUnreachable(err)
+	}
+
+	allowed := !isNotWhitelisted(reply)
+	if !allowed {
+		log.WithFields(log.Fields{"host": host, "command": cmd}).Info("command is not in the 4lw.commands.whitelist, modules using it are skipped")
+	}
+
+	capabilitiesMu.Lock()
+	delete(unreachable, host)
+	if capabilities[host] == nil {
+		capabilities[host] = make(map[string]capability)
+	}
+	capabilities[host][cmd] = capability{allowed: allowed, detected: time.Now()}
+	capabilitiesMu.Unlock()
+
+	return allowed
+}
+
+// evictCapabilities 删除host的探测结果，probe的exporter被丢弃时调用，避免任意target使缓存无限增长
+func evictCapabilities(host string) {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	delete(capabilities, host)
+	delete(unreachable, host)
+}
+
+// moduleSupported reports whether the commands module depends on are allowed by host.
+// The admin transport does not use the whitelist, so every module is supported.
+func moduleSupported(host string, module string) bool {
+	if config.Transport == transportAdmin {
+		return true
+	}
+
+	for _, alternatives := range moduleCommands[module] {
+		supported := false
+		for _, cmd := range alternatives {
+			if commandAllowed(host, cmd) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+	return true
+}
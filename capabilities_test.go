@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommandAllowedExpires(t *testing.T) {
+	host := "192.0.2.1:2181"
+	defer evictCapabilities(host)
+
+	capabilities[host] = map[string]capability{"mntr": {allowed: false, detected: time.Now()}}
+	if commandAllowed(host, "mntr") {
+		t.Errorf("commandAllowed() = true for a fresh negative result")
+	}
+
+	// 过期之后重新探测，host刚刚连接失败时结果未知，返回true
+	capabilities[host]["mntr"] = capability{allowed: false, detected: time.Now().Add(-capabilityTTL)}
+	unreachable[host] = time.Now()
+	if !commandAllowed(host, "mntr") {
+		t.Errorf("commandAllowed() = false for an expired negative result")
+	}
+}
+
+func TestEvictProbeExportersEvictsCapabilities(t *testing.T) {
+	host := "192.0.2.2:2181"
+	now := time.Now()
+
+	capabilities[host] = map[string]capability{"mntr": {allowed: true, detected: now}}
+	unreachable[host] = now
+	probeExporters[host] = &probeEntry{lastUsed: now.Add(-2 * probeExporterTTL)}
+
+	probeExportersMu.Lock()
+	evictProbeExporters(now)
+	probeExportersMu.Unlock()
+
+	if _, ok := probeExporters[host]; ok {
+		t.Errorf("probe exporter of %s was not evicted", host)
+	}
+	if _, ok := capabilities[host]; ok {
+		t.Errorf("capabilities of %s were not evicted", host)
+	}
+	if _, ok := unreachable[host]; ok {
+		t.Errorf("unreachable entry of %s was not evicted", host)
+	}
+}
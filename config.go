@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"os"
 
 	"github.com/tkanos/gonfig"
@@ -17,8 +18,27 @@ var (
 		PublishPort:        "9419",
 		PublishAddr:        "",
 		OutputFormat:       "TTY", //JSON
-		EnabledExporters:   []string{"ruok", "mntr", "cons"},
+		EnabledExporters:   []string{"ruok", "mntr", "cons", "wchs"},
 		ExtraLabels:		nil,
+		ClusterName:        "",
+		MntrPrefix:         "mntr_",
+		LegacyMetricNames:  false,
+		ZnodePaths:         []string{"/"},
+		CanaryPath:         "/zookeeper_exporter",
+		Transport:          "4lw",
+		NativePort:         "7000",
+		NativePrefix:       "native_",
+		Admin: adminConfig{
+			Port:   "8080",
+			Scheme: "http",
+		},
+		Crawler: crawlerConfig{
+			Roots:    []string{"/"},
+			Prefixes: nil,
+			MaxDepth: 10,
+			MaxNodes: 10000,
+			Interval: 300,
+		},
 	}
 )
 
@@ -30,6 +50,75 @@ type zookeeperExporterConfig struct {
 	OutputFormat             string              `json:"output_format"`
 	EnabledExporters		 []string            `json:"enabled_exporters"`
 	ExtraLabels              []map[string]string `json:"extra_labels"`
+	ClusterName              string              `json:"cluster_name"`
+	MntrPrefix               string              `json:"mntr_prefix"`
+	LegacyMetricNames        bool                `json:"legacy_metric_names"`
+	ZnodePaths               []string            `json:"znode_paths"`
+	Crawler                  crawlerConfig       `json:"crawler"`
+	ZnodeValues              []znodeValueRule    `json:"znode_values"`
+	CanaryPath               string              `json:"canary_path"`
+	Auth                     authConfig          `json:"auth"`
+	TLS                      tlsConfig           `json:"tls"`
+	TLSTargets               map[string]tlsConfig `json:"tls_targets"`
+	Transport                string              `json:"transport"`
+	Admin                    adminConfig         `json:"admin"`
+	NativePort               string              `json:"native_port"`
+	NativePrefix             string              `json:"native_prefix"`
+}
+
+// znodeValueRule 把一个znode的内容导出为gauge，json_path和regex用于从内容中提取数值
+type znodeValueRule struct {
+	Path     string            `json:"path"`
+	Name     string            `json:"name"`
+	Help     string            `json:"help"`
+	Labels   map[string]string `json:"labels"`
+	JSONPath string            `json:"json_path"`
+	Regex    string            `json:"regex"`
+}
+
+// authConfig 访问有ACL的znode时使用的认证方式，mechanism为digest或者sasl，
+// 凭证格式为user:password，从credentials_file文件或者credentials_env环境变量中读取，不直接写在配置中
+type authConfig struct {
+	Mechanism       string `json:"mechanism"`
+	CredentialsFile string `json:"credentials_file"`
+	CredentialsEnv  string `json:"credentials_env"`
+}
+
+// String describes where the credentials come from without revealing them
+func (a authConfig) String() string {
+	switch {
+	case a.Mechanism == "":
+		return "none"
+	case a.CredentialsFile != "":
+		return a.Mechanism + " (credentials from file " + a.CredentialsFile + ")"
+	case a.CredentialsEnv != "":
+		return a.Mechanism + " (credentials from env " + a.CredentialsEnv + ")"
+	}
+	return a.Mechanism + " (no credentials)"
+}
+
+// tlsConfig 连接secureClientPort时使用，tls_targets可以按照ip:port为单个target单独配置
+type tlsConfig struct {
+	Enabled    bool   `json:"enabled"`
+	CAFile     string `json:"ca_file"`
+	CertFile   string `json:"cert_file"`
+	KeyFile    string `json:"key_file"`
+	ServerName string `json:"server_name"`
+	MinVersion string `json:"min_version"`
+}
+
+// adminConfig transport为admin时AdminServer的端口和协议，https使用tls中的证书配置
+type adminConfig struct {
+	Port   string `json:"port"`
+	Scheme string `json:"scheme"`
+}
+
+type crawlerConfig struct {
+	Roots    []string `json:"roots"`
+	Prefixes []string `json:"prefixes"`
+	MaxDepth int      `json:"max_depth"`
+	MaxNodes int      `json:"max_nodes"`
+	Interval int      `json:"interval"`
 }
 
 func initConfigFromFile(configFile string) error {
@@ -42,6 +131,27 @@ func initConfigFromFile(configFile string) error {
 	return nil
 }
 
+// validateConfig 检查配置文件和环境变量都可能设置的值
+func validateConfig() {
+	if config.MntrPrefix == "" {
+		// 没有前缀时mntr中未配置的key(例如zk_watch_count)会和其他module的指标重名，导致/metrics返回500
+		panic(fmt.Errorf("mntr prefix must not be empty, dynamic mntr metrics would clash with the metrics of other modules"))
+	}
+
+	if config.NativePrefix == "" {
+		// 同样的原因，native的指标(例如watch_count)会和wchs以及mntr的指标重名
+		panic(fmt.Errorf("native prefix must not be empty, native metrics would clash with the metrics of other modules"))
+	}
+	if strings.HasPrefix(config.NativePrefix, config.MntrPrefix) || strings.HasPrefix(config.MntrPrefix, config.NativePrefix) {
+		// mntr和native暴露的key大多相同，一个前缀是另一个的前缀时指标会重名
+		panic(fmt.Errorf("native prefix '%s' and mntr prefix '%s' must not overlap", config.NativePrefix, config.MntrPrefix))
+	}
+
+	if config.Crawler.Interval <= 0 {
+		panic(fmt.Errorf("crawler interval must be a positive number of seconds: %v", config.Crawler.Interval))
+	}
+}
+
 func initConfig() {
 	config = defaultConfig
 	if host := os.Getenv("ZK_HOST"); host != "" {
@@ -77,6 +187,90 @@ func initConfig() {
 		config.Timeout = t
 	}
 
+	if cluster := os.Getenv("CLUSTER_NAME"); cluster != "" {
+		config.ClusterName = cluster
+	}
+
+	if prefix, ok := os.LookupEnv("MNTR_PREFIX"); ok {
+		config.MntrPrefix = prefix
+	}
+
+	if legacy := os.Getenv("LEGACY_METRIC_NAMES"); legacy != "" {
+		l, err := strconv.ParseBool(legacy)
+		if err != nil {
+			panic(fmt.Errorf("legacy metric names is not a bool: %v", err))
+		}
+		config.LegacyMetricNames = l
+	}
+
+	if paths := os.Getenv("ZNODE_PATHS"); paths != "" {
+		config.ZnodePaths = strings.Split(paths, ",")
+	}
+
+	if canary := os.Getenv("CANARY_PATH"); canary != "" {
+		config.CanaryPath = canary
+	}
+
+	if mechanism := os.Getenv("AUTH_MECHANISM"); mechanism != "" {
+		config.Auth.Mechanism = mechanism
+	}
+
+	if file := os.Getenv("AUTH_CREDENTIALS_FILE"); file != "" {
+		config.Auth.CredentialsFile = file
+	}
+
+	if env := os.Getenv("AUTH_CREDENTIALS_ENV"); env != "" {
+		config.Auth.CredentialsEnv = env
+	}
+
+	if enabled := os.Getenv("TLS_ENABLED"); enabled != "" {
+		e, err := strconv.ParseBool(enabled)
+		if err != nil {
+			panic(fmt.Errorf("tls enabled is not a bool: %v", err))
+		}
+		config.TLS.Enabled = e
+	}
+
+	if ca := os.Getenv("TLS_CA_FILE"); ca != "" {
+		config.TLS.CAFile = ca
+	}
+
+	if cert := os.Getenv("TLS_CERT_FILE"); cert != "" {
+		config.TLS.CertFile = cert
+	}
+
+	if key := os.Getenv("TLS_KEY_FILE"); key != "" {
+		config.TLS.KeyFile = key
+	}
+
+	if name := os.Getenv("TLS_SERVER_NAME"); name != "" {
+		config.TLS.ServerName = name
+	}
+
+	if version := os.Getenv("TLS_MIN_VERSION"); version != "" {
+		config.TLS.MinVersion = version
+	}
+
+	if transport := os.Getenv("TRANSPORT"); transport != "" {
+		config.Transport = transport
+	}
+
+	if port := os.Getenv("ADMIN_PORT"); port != "" {
+		config.Admin.Port = port
+	}
+
+	if scheme := os.Getenv("ADMIN_SCHEME"); scheme != "" {
+		config.Admin.Scheme = scheme
+	}
+
+	if port := os.Getenv("NATIVE_PORT"); port != "" {
+		config.NativePort = port
+	}
+
+	if prefix, ok := os.LookupEnv("NATIVE_PREFIX"); ok {
+		config.NativePrefix = prefix
+	}
+
 	//if extraLabels := os.Getenv("EXTRA_LABELS"); extraLabels != "" {
 	//
 	//}
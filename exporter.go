@@ -20,6 +20,8 @@ const (
 	endpointScrapeDuration contextValues = "endpointScrapeDuration"
 	endpointUpMetric       contextValues = "endpointUpMetric"
 	nodeName               contextValues = "node"
+	zkHost                 contextValues = "zkHost"
+	clusterID              contextValues = "cluster"
 )
 
 //RegisterExporter makes an exporter available by the provided name.
@@ -37,8 +39,11 @@ type exporter struct {
 	upMetric                     *prometheus.GaugeVec
 	endpointUpMetric             *prometheus.GaugeVec
 	endpointScrapeDurationMetric *prometheus.GaugeVec
+	moduleSupportedMetric        *prometheus.GaugeVec
 	confExporter             	 *exporterConf
 	exporter                     map[string]Exporter
+	host                         string
+	cluster                      string
 	self                         string
 	lastScrapeOK                 bool
 }
@@ -49,7 +54,8 @@ type Exporter interface {
 	Describe(ch chan<- *prometheus.Desc)
 }
 
-func newExporter() *exporter {
+// newExporter 创建一个采集host(ip:port)的exporter，启用的module由config.EnabledExporters决定
+func newExporter(host string, cluster string) *exporter {
 	enabledExporter := make(map[string]Exporter)
 	for _, e := range config.EnabledExporters {
 		if _, ok := exporterFactories[e]; ok {
@@ -61,8 +67,11 @@ func newExporter() *exporter {
 		upMetric:                     newGaugeVec("exporter_up", "Was the last scrape of zookeeper successful.", "node"),
 		endpointUpMetric:             newGaugeVec("exporter_module_up", "Was the last scrape of zookeeper successful per module.", "node", "module"),
 		endpointScrapeDurationMetric: newGaugeVec("module_scrape_duration_seconds", "Duration of the last scrape in seconds", "node", "module"),
+		moduleSupportedMetric:        newGaugeVec("exporter_module_supported", "Are the four letter words the module depends on allowed by zookeeper, unsupported modules are skipped.", "node", "module"),
 		confExporter:            	  newExporterConf(),
 		exporter:                     enabledExporter,
+		host:                         host,
+		cluster:                      cluster,
 		lastScrapeOK:                 true, //return true after start. Value will be updated with each scraping
 	}
 }
@@ -82,6 +91,7 @@ func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.upMetric.Describe(ch)
 	e.endpointUpMetric.Describe(ch)
 	e.endpointScrapeDurationMetric.Describe(ch)
+	e.moduleSupportedMetric.Describe(ch)
 	BuildInfo.Describe(ch)
 }
 
@@ -94,12 +104,23 @@ func (e *exporter) Collect(ch chan<- prometheus.Metric) {
 	start := time.Now()
 	allUp := true
 
-	if err := e.collectWithDuration(e.confExporter, "conf", ch); err != nil {
+	confSupported := moduleSupported(e.host, "conf")
+	if !confSupported {
+		// 没有conf时无法得到ip:port形式的node，直接使用host
+		e.confExporter.nodeInfo.Node = e.host
+	} else if err := e.collectWithDuration(e.confExporter, "conf", ch); err != nil {
 		log.WithError(err).Warn("retrieving overview failed")
 		allUp = false
 	}
+	e.setModuleSupported("conf", confSupported)
 
 	for name, ex := range e.exporter {
+		// 依赖的四字命令不在白名单中的module直接跳过，不影响exporter_up
+		supported := moduleSupported(e.host, name)
+		e.setModuleSupported(name, supported)
+		if !supported {
+			continue
+		}
 		if err := e.collectWithDuration(ex, name, ch); err != nil {
 			log.WithError(err).Warn("retrieving " + name + " failed")
 			allUp = false
@@ -116,6 +137,7 @@ func (e *exporter) Collect(ch chan<- prometheus.Metric) {
 	e.upMetric.Collect(ch)
 	e.endpointUpMetric.Collect(ch)
 	e.endpointScrapeDurationMetric.Collect(ch)
+	e.moduleSupportedMetric.Collect(ch)
 
 	BuildInfo.Collect(ch)
 
@@ -123,10 +145,20 @@ func (e *exporter) Collect(ch chan<- prometheus.Metric) {
 
 }
 
+func (e *exporter) setModuleSupported(name string, supported bool) {
+	if supported {
+		gaugeVecWithLabelValues(e.moduleSupportedMetric, e.confExporter.NodeInfo().Node, name).Set(1)
+	} else {
+		gaugeVecWithLabelValues(e.moduleSupportedMetric, e.confExporter.NodeInfo().Node, name).Set(0)
+	}
+}
+
 func (e *exporter) collectWithDuration(ex Exporter, name string, ch chan<- prometheus.Metric) error {
 	// 定义传给各个exporter.Collect的上下文
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, nodeName, e.confExporter.NodeInfo().Node)
+	ctx = context.WithValue(ctx, zkHost, e.host)
+	ctx = context.WithValue(ctx, clusterID, e.cluster)
 
 	startModule := time.Now()
 	err := ex.Collect(ctx, ch)
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	RegisterExporter("acl", newExporterACL)
+}
+
+// world:anyone拥有这些权限中的任意一个都会被认为是不安全的
+const worldUnsafePerms = permWrite | permDelete | permAdmin
+
+type exporterACL struct {
+	aclDesc map[string]*prometheus.Desc
+}
+
+func newExporterACL() Exporter {
+	aclDescActual := map[string]*prometheus.Desc{
+		"nodes":          newDesc("znode_acl_nodes", "Number of znodes below the prefix granting perm to scheme.", "node", "prefix", "scheme", "perm"),
+		"world_writable": newDesc("znode_acl_world_writable_nodes", "Number of znodes below the prefix granting write, delete or admin to world:anyone.", "node", "prefix"),
+		"unknown":        newDesc("znode_acl_unknown_nodes", "Number of znodes below the prefix whose ACL the exporter is not allowed to read.", "node", "prefix"),
+	}
+
+	return &exporterACL{
+		aclDesc: aclDescActual,
+	}
+}
+
+// aclAuditEnabled 只有启用了acl模块时crawler才会对每个节点执行getACL
+func aclAuditEnabled() bool {
+	for _, e := range config.EnabledExporters {
+		if e == "acl" {
+			return true
+		}
+	}
+	return false
+}
+
+// worldWritablePerms returns the unsafe perms granted to world:anyone, 0 if none.
+func worldWritablePerms(acls []zkACL) int32 {
+	var perms int32
+	for _, acl := range acls {
+		if acl.Scheme == "world" && acl.ID == "anyone" {
+			perms |= acl.Perms & worldUnsafePerms
+		}
+	}
+	return perms
+}
+
+func (e exporterACL) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	// ACL和subtree共用crawler的遍历结果
+	result, err := getCrawler(host).Result()
+	if result == nil || ch == nil {
+		return err
+	}
+
+	for prefix, stats := range result.prefixes {
+		for key, n := range stats.acls {
+			ch <- mustNewConstMetric(e.aclDesc["nodes"], prometheus.GaugeValue, n, node, prefix, key.scheme, key.perm)
+		}
+		ch <- mustNewConstMetric(e.aclDesc["world_writable"], prometheus.GaugeValue, stats.worldWritable, node, prefix)
+		ch <- mustNewConstMetric(e.aclDesc["unknown"], prometheus.GaugeValue, stats.aclUnknown, node, prefix)
+	}
+
+	return err
+}
+
+func (e exporterACL) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.aclDesc {
+		ch <- desc
+	}
+
+}
+
+// aclAuditHandler 列出最近一次遍历中world:anyone可写的znode，每行一个，target参数与/probe相同。
+// ACL不可读的节点以unknown列出。只返回已经被采集过的target，不会为任何target创建crawler
+func aclAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if !aclAuditEnabled() {
+		http.Error(w, "the acl module is not enabled", http.StatusNotFound)
+		return
+	}
+
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		target = config.ZkHost
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, defaultZkPort)
+	}
+
+	crawler := lookupCrawler(target)
+	if crawler == nil {
+		if target == config.ZkHost {
+			http.Error(w, "no crawl of "+target+" started yet, scrape /metrics first", http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, "'"+target+"' is not crawled, probe it with the acl module first", http.StatusNotFound)
+		}
+		return
+	}
+
+	result, err := crawler.Result()
+	if result == nil {
+		msg := "no crawl of " + target + " finished yet"
+		if err != nil {
+			msg += ": " + err.Error()
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return
+	}
+
+	offenders := append([]aclOffender(nil), result.offenders...)
+	sort.Slice(offenders, func(i, j int) bool { return offenders[i].path < offenders[j].path })
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, o := range offenders {
+		fmt.Fprintf(w, "%s\t%s\n", o.path, o.acl)
+	}
+
+	unknown := append([]string(nil), result.unknown...)
+	sort.Strings(unknown)
+	for _, path := range unknown {
+		fmt.Fprintf(w, "%s\tunknown\n", path)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("canary", newExporterCanary)
+}
+
+// canary依次执行的操作
+var canaryOperations = []string{"connect", "create", "set", "get", "delete"}
+
+type exporterCanary struct {
+	latency    *prometheus.HistogramVec
+	operations *prometheus.CounterVec
+}
+
+func newExporterCanary() Exporter {
+	return &exporterCanary{
+		latency:    newHistogramVec("canary_operation_duration_seconds", "Latency of the successful canary operations.", prometheus.ExponentialBuckets(0.0005, 2, 15), "node", "operation"),
+		operations: newCounterVec("canary_operations_total", "Number of canary operations by result.", "node", "operation", "result"),
+	}
+}
+
+// canaryNode 返回本exporter使用的临时节点，包含hostname以免多个exporter互相干扰
+func canaryNode(node string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return childPath(config.CanaryPath, sanitizeMetricName(hostname+"_"+node))
+}
+
+// probeACL 是canary、replication和watch创建的节点使用的ACL，配置了auth时只有exporter的身份可以访问。
+// 没有配置auth时沿用canary_path的ACL，可以预先创建canary_path并限制其ACL(例如ip:<exporter地址>:cdrwa)，
+// canary_path不存在时只能使用world:anyone。canary_path下的节点不计入ACL审计
+func probeACL(session *zkSession) []zkACL {
+	if config.Auth.Mechanism != "" {
+		return creatorACL
+	}
+	if acls, _, err := session.GetACL(config.CanaryPath); err == nil && len(acls) > 0 {
+		return acls
+	}
+	return worldACL
+}
+
+// ensurePath 逐级创建path及其父节点，已经存在的节点会被忽略
+func ensurePath(session *zkSession, path string) error {
+	current := "/"
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current = childPath(current, part)
+		if _, err := session.Create(current, nil, probeACL(session), flagPersistent); err != nil && err != errNodeExists {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e exporterCanary) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	if ch != nil {
+		// 失败时也要导出已经记录的延迟和计数
+		defer func() {
+			e.latency.Collect(ch)
+			e.operations.Collect(ch)
+		}()
+	}
+
+	for _, op := range canaryOperations {
+		for _, result := range []string{"success", "failure"} {
+			counterVecWithLabelValues(e.operations, node, op, result)
+		}
+	}
+
+	observe := func(op string, start time.Time, err error) error {
+		if err != nil {
+			counterVecWithLabelValues(e.operations, node, op, "failure").Inc()
+			return fmt.Errorf("canary %s failed: %v", op, err)
+		}
+		histogramVecWithLabelValues(e.latency, node, op).Observe(time.Since(start).Seconds())
+		counterVecWithLabelValues(e.operations, node, op, "success").Inc()
+		return nil
+	}
+
+	start := time.Now()
+	session, err := dialSession(host, defaultSessionTimeout)
+	if err := observe("connect", start, err); err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if err := ensurePath(session, config.CanaryPath); err != nil {
+		return err
+	}
+
+	path := canaryNode(node)
+	data := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	acl := probeACL(session)
+
+	start = time.Now()
+	_, err = session.Create(path, data, acl, flagEphemeral)
+	if err == errNodeExists {
+		// 上一个session的临时节点还没有过期
+		log.WithField("path", path).Debug("canary znode left over from a previous session")
+		if err = session.Delete(path, -1); err == nil || err == errNoNode {
+			start = time.Now()
+			_, err = session.Create(path, data, acl, flagEphemeral)
+		}
+	}
+	if err := observe("create", start, err); err != nil {
+		return err
+	}
+
+	data = []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	start = time.Now()
+	_, err = session.SetData(path, data, -1)
+	if err := observe("set", start, err); err != nil {
+		return err
+	}
+
+	start = time.Now()
+	got, _, err := session.GetData(path)
+	if err == nil && !bytes.Equal(got, data) {
+		err = fmt.Errorf("read %q after writing %q", got, data)
+	}
+	if err := observe("get", start, err); err != nil {
+		return err
+	}
+
+	start = time.Now()
+	err = session.Delete(path, -1)
+	if err := observe("delete", start, err); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e exporterCanary) Describe(ch chan<- *prometheus.Desc) {
+	e.latency.Describe(ch)
+	e.operations.Describe(ch)
+
+}
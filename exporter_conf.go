@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -38,6 +40,108 @@ func (e exporterConf) NodeInfo() NodeInfo {
 	return e.nodeInfo
 }
 
+//Member presents a server listed in the membership section of conf
+type Member struct {
+	ID   string
+	Host string
+	Role string
+}
+
+// splitServerAddress 拆分server.N中;之前的部分，ipv6的地址带中括号，例如[2001:db8::1]:2888:3888:participant。
+// 3.6+的多地址格式 10.0.0.1:2888:3888|10.1.0.1:2888:3888:participant 使用第一个地址和最后的role
+func splitServerAddress(server string) (string, []string) {
+	if i := strings.Index(server, "|"); i >= 0 {
+		host, ports := splitServerAddress(server[:i])
+		if _, last := splitServerAddress(server[strings.LastIndex(server, "|")+1:]); len(last) >= 3 && len(ports) < 3 {
+			ports = append(ports, last[2])
+		}
+		return host, ports
+	}
+	if strings.HasPrefix(server, "[") {
+		if end := strings.Index(server, "]"); end >= 0 {
+			rest := strings.TrimPrefix(server[end+1:], ":")
+			if rest == "" {
+				return server[1:end], nil
+			}
+			return server[1:end], strings.Split(rest, ":")
+		}
+	}
+	addr := strings.Split(server, ":")
+	return addr[0], addr[1:]
+}
+
+// memberIDLess 按照数字顺序比较server id，server.10排在server.2之后
+func memberIDLess(a string, b string) bool {
+	i, errA := strconv.Atoi(a)
+	j, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return i < j
+}
+
+// parseMembers 解析conf中membership部分的server.N=行，得到集群所有成员的客户端地址
+// 3.5+的格式为 server.1=10.0.0.1:2888:3888:participant;0.0.0.0:2181
+// secure为true时使用conf中的secureClientPort，membership中没有这个端口，假设所有成员相同
+func parseMembers(conf MetricMap, secure bool) []Member {
+	var members []Member
+
+	for key, value := range conf {
+		if !strings.HasPrefix(key, "server.") {
+			continue
+		}
+
+		member := Member{
+			ID:   strings.TrimPrefix(key, "server."),
+			Role: "participant",
+		}
+
+		server := value
+		client := conf["clientPort"]
+		if i := strings.Index(value, ";"); i >= 0 {
+			server = value[:i]
+			client = value[i+1:]
+		}
+
+		serverHost, ports := splitServerAddress(server)
+		if len(ports) >= 3 {
+			member.Role = ports[2]
+		}
+
+		clientHost, clientPort, err := net.SplitHostPort(client)
+		if err != nil {
+			clientHost, clientPort = "", client
+		}
+		if clientHost == "" || clientHost == "0.0.0.0" || clientHost == "::" {
+			clientHost = serverHost
+		}
+		if secure && conf["secureClientPort"] != "" {
+			clientPort = conf["secureClientPort"]
+		}
+		if clientPort == "" {
+			continue
+		}
+
+		member.Host = net.JoinHostPort(clientHost, clientPort)
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return memberIDLess(members[i].ID, members[j].ID) })
+
+	return members
+}
+
+// confMembers 返回host的conf中列出的成员，host启用了TLS时使用secureClientPort，
+// 并且没有在tls_targets中单独配置的成员沿用host的TLS配置
+func confMembers(host string, conf MetricMap) []Member {
+	tc := tlsConfigFor(host)
+	members := parseMembers(conf, tc.Enabled)
+	for _, member := range members {
+		inheritTLSConfig(member.Host, tc)
+	}
+	return members
+}
+
 func makeConfStatsInfo(body []byte, labels ...string) []StatsInfo {
 	var q []StatsInfo
 
@@ -53,7 +157,10 @@ func makeConfStatsInfo(body []byte, labels ...string) []StatsInfo {
 			continue
 		}
 
-		kv := strings.Split(line, "=")
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
 
 		statsinfo.metrics[kv[0]] = kv[1]
 	}
@@ -64,7 +171,12 @@ func makeConfStatsInfo(body []byte, labels ...string) []StatsInfo {
 }
 
 func (e *exporterConf) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
-	zkConfData, err := getStatsInfo(makeConfStatsInfo, "conf")
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkConfData, err := getStatsInfo(host, makeConfStatsInfo, "conf")
 	if err != nil {
 		return err
 	}
@@ -73,14 +185,12 @@ func (e *exporterConf) Collect(ctx context.Context, ch chan<- prometheus.Metric)
 
 	serverId := zkConfData[0].metrics["serverId"]
 
-	host := ""
-	if zkHost, ok := zkConfData[0].metrics["server." + serverId]; ok {
-		host = strings.Split(zkHost, ":")[0] + port
-	} else {
-		host = config.ZkHost
+	node := host
+	if server, ok := zkConfData[0].metrics["server." + serverId]; ok && port != "" {
+		node = strings.Split(server, ":")[0] + ":" + port
 	}
 
-	e.nodeInfo.Node = host
+	e.nodeInfo.Node = node
 
 	log.WithField("confData", zkConfData).Debug("Conf data")
 
@@ -88,7 +198,7 @@ func (e *exporterConf) Collect(ctx context.Context, ch chan<- prometheus.Metric)
 		for _, conf := range zkConfData {
 			if value, ok := conf.metrics[key]; ok {
 				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set conf metric for key")
-				v, err := strconv.ParseFloat(value, 64)
+				v, err := parseValue(value)
 				if err != nil {
 					log.WithFields(log.Fields{"key": key, "value": value}).Error("conv value to float64 failed")
 					continue
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMembers(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		conf   MetricMap
+		secure bool
+		want   []Member
+	}{
+		{
+			name: "standalone",
+			conf: MetricMap{"clientPort": "2181", "serverId": "0"},
+		},
+		{
+			name: "3.5 membership",
+			conf: MetricMap{
+				"clientPort": "2181",
+				"server.1":   "10.0.0.1:2888:3888:participant;0.0.0.0:2181",
+				"server.2":   "10.0.0.2:2888:3888:participant;10.0.1.2:2182",
+				"server.3":   "10.0.0.3:2888:3888:observer;2181",
+			},
+			want: []Member{
+				{ID: "1", Host: "10.0.0.1:2181", Role: "participant"},
+				{ID: "2", Host: "10.0.1.2:2182", Role: "participant"},
+				{ID: "3", Host: "10.0.0.3:2181", Role: "observer"},
+			},
+		},
+		{
+			name: "3.4 servers use clientPort",
+			conf: MetricMap{
+				"clientPort": "2181",
+				"server.1":   "10.0.0.1:2888:3888",
+				"server.2":   "10.0.0.2:2888:3888",
+			},
+			want: []Member{
+				{ID: "1", Host: "10.0.0.1:2181", Role: "participant"},
+				{ID: "2", Host: "10.0.0.2:2181", Role: "participant"},
+			},
+		},
+		{
+			name: "ipv6",
+			conf: MetricMap{
+				"server.1": "[2001:db8::1]:2888:3888:participant;[::]:2181",
+				"server.2": "[2001:db8::2]:2888:3888:observer;[2001:db8:1::2]:2182",
+			},
+			want: []Member{
+				{ID: "1", Host: "[2001:db8::1]:2181", Role: "participant"},
+				{ID: "2", Host: "[2001:db8:1::2]:2182", Role: "observer"},
+			},
+		},
+		{
+			name: "multiple addresses",
+			conf: MetricMap{
+				"server.1": "10.0.0.1:2888:3888|10.1.0.1:2888:3888:participant;0.0.0.0:2181",
+				"server.2": "10.0.0.2:2888:3888|10.1.0.2:2888:3888:observer;0.0.0.0:2181",
+			},
+			want: []Member{
+				{ID: "1", Host: "10.0.0.1:2181", Role: "participant"},
+				{ID: "2", Host: "10.0.0.2:2181", Role: "observer"},
+			},
+		},
+		{
+			name: "numeric ids",
+			conf: MetricMap{
+				"server.10": "10.0.0.10:2888:3888:participant;2181",
+				"server.2":  "10.0.0.2:2888:3888:participant;2181",
+				"server.1":  "10.0.0.1:2888:3888:participant;2181",
+			},
+			want: []Member{
+				{ID: "1", Host: "10.0.0.1:2181", Role: "participant"},
+				{ID: "2", Host: "10.0.0.2:2181", Role: "participant"},
+				{ID: "10", Host: "10.0.0.10:2181", Role: "participant"},
+			},
+		},
+		{
+			name: "secureClientPort",
+			conf: MetricMap{
+				"clientPort":       "2181",
+				"secureClientPort": "2281",
+				"server.1":         "10.0.0.1:2888:3888:participant;0.0.0.0:2181",
+				"server.2":         "10.0.0.2:2888:3888:participant;10.0.1.2:2181",
+			},
+			secure: true,
+			want: []Member{
+				{ID: "1", Host: "10.0.0.1:2281", Role: "participant"},
+				{ID: "2", Host: "10.0.1.2:2281", Role: "participant"},
+			},
+		},
+		{
+			name: "TLS only",
+			conf: MetricMap{
+				"secureClientPort": "2281",
+				"server.1":         "10.0.0.1:2888:3888:participant",
+				"server.2":         "10.0.0.2:2888:3888:participant",
+			},
+			secure: true,
+			want: []Member{
+				{ID: "1", Host: "10.0.0.1:2281", Role: "participant"},
+				{ID: "2", Host: "10.0.0.2:2281", Role: "participant"},
+			},
+		},
+		{
+			name: "secureClientPort without TLS",
+			conf: MetricMap{
+				"secureClientPort": "2281",
+				"server.1":         "10.0.0.1:2888:3888:participant",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseMembers(tc.conf, tc.secure); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseMembers() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -13,64 +13,114 @@ func init() {
 }
 
 type exporterCons struct {
-	consGauge		map[string]*prometheus.GaugeVec
+	consDesc		map[string]*prometheus.Desc
+}
+
+var consLabelNames = []string{"node", "client_ip", "client_port", "session_id", "interest_ops"}
+
+// cons中的时间戳、超时时间和延迟都是毫秒
+var consUnits = map[string]metricUnit{
+	"est":    unitMilliseconds,
+	"to":     unitMilliseconds,
+	"lresp":  unitMilliseconds,
+	"llat":   unitMilliseconds,
+	"minlat": unitMilliseconds,
+	"avglat": unitMilliseconds,
+	"maxlat": unitMilliseconds,
+}
+
+// consTimestampName est和lresp是unix时间戳，按照prometheus的命名规范使用_timestamp_seconds后缀，
+// config.LegacyMetricNames为true时保持原来的名字
+func consTimestampName(name string) string {
+	if config.LegacyMetricNames {
+		return name
+	}
+	return unitMetricName(name+"_timestamp", consUnits["est"])
 }
 
 func newExporterCons() Exporter {
-	consaugeVecActual := map[string]*prometheus.GaugeVec{
-		"queued":            newGaugeVec("client_queued", "Client queue.", "node", "client"),
-		"recved":            newGaugeVec("client_recved", "Number of packets received by the client.", "node", "client"),
-		"sent":            newGaugeVec("client_sent", "Number of packets sent by the client.", "node", "client"),
-		"sid":            newGaugeVec("client_sid", "Client Session Id.", "node", "client"),
-		"lop":            newGaugeVec("client_lop", "Client last operation instructions.", "node", "client"),
-		"est":            newGaugeVec("client_est", "Client connection timestamp.", "node", "client"),
-		"to":            newGaugeVec("client_to", "Client connection timeout.", "node", "client"),
-		"lcxid":            newGaugeVec("client_lcxid", "The last id of the client (no specific id confirmed).", "node", "client"),
-		"lzxid":            newGaugeVec("client_lzxid", "The last id of the client (state change id).", "node", "client"),
-		"lresp":            newGaugeVec("client_lresp", "Client last response timestamp.", "node", "client"),
-		"llat":				newGaugeVec("client_llat", "Client last delay.", "node", "client"),
-		"minlat":            newGaugeVec("client_minlat", "Client Minimum delay.", "node", "client"),
-		"avglat":            newGaugeVec("client_avglat", "Client Average delay.", "node", "client"),
-		"maxlat":            newGaugeVec("client_maxlat", "Client Maximum delay.", "node", "client"),
+	consDescActual := map[string]*prometheus.Desc{
+		"queued":            newDesc("client_queued", "Client queue.", consLabelNames...),
+		"recved":            newDesc("client_recved", "Number of packets received by the client.", consLabelNames...),
+		"sent":              newDesc("client_sent", "Number of packets sent by the client.", consLabelNames...),
+		"est":               newDesc(consTimestampName("client_est"), "Client connection timestamp.", consLabelNames...),
+		"to":                newDesc(unitMetricName("client_to", consUnits["to"]), "Client connection timeout.", consLabelNames...),
+		"lcxid":             newDesc("client_lcxid", "The last id of the client (no specific id confirmed).", consLabelNames...),
+		"lzxid":             newDesc("client_lzxid", "The last id of the client (state change id).", consLabelNames...),
+		"lzxid_epoch":       newDesc("client_lzxid_epoch", "Epoch of the last zxid of the client.", consLabelNames...),
+		"lzxid_counter":     newDesc("client_lzxid_counter", "Counter of the last zxid of the client.", consLabelNames...),
+		"lresp":             newDesc(consTimestampName("client_lresp"), "Client last response timestamp.", consLabelNames...),
+		"llat":              newDesc(unitMetricName("client_llat", consUnits["llat"]), "Client last delay.", consLabelNames...),
+		"minlat":            newDesc(unitMetricName("client_minlat", consUnits["minlat"]), "Client Minimum delay.", consLabelNames...),
+		"avglat":            newDesc(unitMetricName("client_avglat", consUnits["avglat"]), "Client Average delay.", consLabelNames...),
+		"maxlat":            newDesc(unitMetricName("client_maxlat", consUnits["maxlat"]), "Client Maximum delay.", consLabelNames...),
 	}
 
 	return &exporterCons{
-		consGauge: consaugeVecActual,
+		consDesc: consDescActual,
 	}
 }
 
+// cons每一行是一个连接，格式如下(ipv6的地址不带中括号):
+//   /127.0.0.1:56980[1](queued=0,recved=10,sent=9,sid=0x10000a4b1e20001,lop=PING,est=1612345678901,...)
+//   /0:0:0:0:0:0:0:1:56982[0](queued=0,recved=1,sent=0)
 func makeConsStatsInfo(body []byte, labels ...string) []StatsInfo {
 	var q []StatsInfo
 
-	statsinfo := StatsInfo{}
-	statsinfo.labels = make(map[string]string)
-	statsinfo.metrics = make(MetricMap)
-
 	reply := string(body)
 
 	lines := strings.Split(reply, "\n")
 
 	for _, line := range lines {
-		if line == "" {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			continue
+		}
+
+		open := strings.Index(line, "[")
+		closeOps := strings.Index(line, "]")
+		if open < 0 || closeOps < open {
+			continue
+		}
+
+		addr := line[1:open]
+		sep := strings.LastIndex(addr, ":")
+		if sep < 0 {
 			continue
 		}
-		cons := strings.Split(reply, "/")[1]
-		s := strings.Split(cons, "(")
-		client := s[0]
 
-		statsinfo.labels["client"] = client
+		statsinfo := StatsInfo{}
+		statsinfo.labels = make(map[string]string)
+		statsinfo.metrics = make(MetricMap)
 
-		m := strings.Split(s[1], ")")[0]
+		statsinfo.labels["client_ip"] = addr[:sep]
+		statsinfo.labels["client_port"] = addr[sep+1:]
+		statsinfo.labels["interest_ops"] = line[open+1 : closeOps]
 
-		metrics := strings.Split(m, ",")
+		m := strings.TrimSuffix(strings.TrimPrefix(line[closeOps+1:], "("), ")")
 
-		for _, metric := range metrics {
-			kv := strings.Split(metric, "=")
+		for _, metric := range strings.Split(m, ",") {
+			kv := strings.SplitN(metric, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
 			statsinfo.metrics[kv[0]] = kv[1]
 		}
-	}
 
-	q = append(q, statsinfo)
+		statsinfo.labels["session_id"] = statsinfo.metrics["sid"]
+
+		if lzxid, ok := statsinfo.metrics["lzxid"]; ok {
+			epoch, counter, err := splitZxid(lzxid)
+			if err != nil {
+				delete(statsinfo.metrics, "lzxid")
+			} else {
+				statsinfo.metrics["lzxid_epoch"] = strconv.FormatUint(epoch, 10)
+				statsinfo.metrics["lzxid_counter"] = strconv.FormatUint(counter, 10)
+			}
+		}
+
+		q = append(q, statsinfo)
+	}
 
 	return q
 }
@@ -81,39 +131,41 @@ func (e exporterCons) Collect(ctx context.Context, ch chan<- prometheus.Metric)
 		node = n
 	}
 
-	zkConsData, err := getStatsInfo(makeConsStatsInfo, "cons")
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkConsData, err := getStatsInfo(host, makeConsStatsInfo, "cons")
 	if err != nil {
 		return err
 	}
 
 	log.WithField("consData", zkConsData).Debug("cons data")
 
-	for key, gauge := range e.consGauge {
+	// 每次采集都重新生成每个连接的指标，已经断开的连接不会再出现
+	for key, desc := range e.consDesc {
 		for _, cons := range zkConsData {
-			client := cons.labels["client"]
 			if value, ok := cons.metrics[key]; ok {
-				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set ruok metric for key")
-				v, err := strconv.ParseFloat(value, 64)
+				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set cons metric for key")
+				v, err := parseValue(value)
 				if err != nil {
 					log.WithFields(log.Fields{"key": key, "value": value}).Error("conv value to float64 failed")
 					continue
 				}
-				gaugeVecWithLabelValues(gauge, node, client).Set(v)
+				if ch != nil {
+					ch <- mustNewConstMetric(desc, prometheus.GaugeValue, unitValue(v, consUnits[key]), node, cons.labels["client_ip"], cons.labels["client_port"], cons.labels["session_id"], cons.labels["interest_ops"])
+				}
 			}
 		}
 	}
 
-	if ch != nil {
-		for _, gauge := range e.consGauge {
-			gauge.Collect(ch)
-		}
-	}
 	return nil
 }
 
 func (e exporterCons) Describe(ch chan<- *prometheus.Desc) {
-	for _, gauge := range e.consGauge {
-		gauge.Describe(ch)
+	for _, desc := range e.consDesc {
+		ch <- desc
 	}
 
 }
\ No newline at end of file
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakeConsStatsInfo(t *testing.T) {
+	body := []byte(` /127.0.0.1:56980[1](queued=0,recved=10,sent=9,sid=0x10000a4b1e20001,lop=PING,est=1612345678901,to=30000,lcxid=0x5,lzxid=0x100000002,lresp=1612345679000,llat=0,minlat=0,avglat=1,maxlat=3)
+ /0:0:0:0:0:0:0:1:56982[0](queued=0,recved=1,sent=0)
+garbage line
+
+`)
+
+	stats := makeConsStatsInfo(body)
+	if len(stats) != 2 {
+		t.Fatalf("got %d connections, want 2", len(stats))
+	}
+
+	wantLabels := map[string]string{
+		"client_ip":    "127.0.0.1",
+		"client_port":  "56980",
+		"interest_ops": "1",
+		"session_id":   "0x10000a4b1e20001",
+	}
+	if !reflect.DeepEqual(stats[0].labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", stats[0].labels, wantLabels)
+	}
+	for key, want := range map[string]string{
+		"recved":        "10",
+		"est":           "1612345678901",
+		"lzxid":         "0x100000002",
+		"lzxid_epoch":   "1",
+		"lzxid_counter": "2",
+		"maxlat":        "3",
+	} {
+		if got := stats[0].metrics[key]; got != want {
+			t.Errorf("metrics[%q] = %q, want %q", key, got, want)
+		}
+	}
+
+	// ipv6的地址不带中括号，没有session的连接session_id为空
+	wantLabels = map[string]string{
+		"client_ip":    "0:0:0:0:0:0:0:1",
+		"client_port":  "56982",
+		"interest_ops": "0",
+		"session_id":   "",
+	}
+	if !reflect.DeepEqual(stats[1].labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", stats[1].labels, wantLabels)
+	}
+	if len(stats[1].metrics) != 3 {
+		t.Errorf("metrics = %v, want queued, recved and sent", stats[1].metrics)
+	}
+}
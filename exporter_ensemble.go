@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("ensemble", newExporterEnsemble)
+}
+
+const clusterLabel = "cluster"
+
+// errNoMembership standalone或者3.4版本的conf没有membership，无法得到集群级别的指标
+var errNoMembership = errors.New("conf does not list the ensemble membership")
+
+type exporterEnsemble struct {
+	ensembleDesc map[string]*prometheus.Desc
+}
+
+// memberState presents the mntr data of one ensemble member
+type memberState struct {
+	Member
+	up      bool
+	state   string
+	version string
+	synced  float64
+}
+
+func newExporterEnsemble() Exporter {
+	ensembleDescActual := map[string]*prometheus.Desc{
+		"members":                newDesc("ensemble_members", "Number of members listed in the membership of conf.", ensembleLabelNames("role")...),
+		"members_alive":          newDesc("ensemble_members_alive", "Number of members answering mntr.", ensembleLabelNames("role")...),
+		"member_up":              newDesc("ensemble_member_up", "Was the last mntr of the member successful.", ensembleLabelNames("member", "role")...),
+		"member_info":            newDesc("ensemble_member_info", "A metric with a constant '1' value labeled by member, role, state and version.", ensembleLabelNames("member", "role", "state", "version")...),
+		"has_leader":             newDesc("ensemble_has_leader", "Does the ensemble have a leader.", ensembleLabelNames()...),
+		"leaders":                newDesc("ensemble_leaders", "Number of members reporting leader state, more than 1 means split brain.", ensembleLabelNames()...),
+		"quorum_size":            newDesc("ensemble_quorum_size", "Number of participants needed for a quorum.", ensembleLabelNames()...),
+		"has_quorum":             newDesc("ensemble_has_quorum", "Are enough participants alive to form a quorum.", ensembleLabelNames()...),
+		"synced_followers_ratio": newDesc("ensemble_synced_followers_ratio", "Synced followers reported by the leader divided by the expected number of followers.", ensembleLabelNames()...),
+		"versions":               newDesc("ensemble_versions", "Number of distinct zookeeper versions running in the ensemble, more than 1 means version skew.", ensembleLabelNames()...),
+	}
+
+	return &exporterEnsemble{
+		ensembleDesc: ensembleDescActual,
+	}
+}
+
+// ensembleLabelNames 集群级别的指标都带cluster标签，如果extra_labels中已经配置了cluster则直接复用
+func ensembleLabelNames(labelNames ...string) []string {
+	for _, name := range extraLabelNames {
+		if name == clusterLabel {
+			return labelNames
+		}
+	}
+	return append([]string{clusterLabel}, labelNames...)
+}
+
+func ensembleLabelValues(cluster string, labelValues ...string) []string {
+	for _, name := range extraLabelNames {
+		if name == clusterLabel {
+			return labelValues
+		}
+	}
+	return append([]string{cluster}, labelValues...)
+}
+
+// clusterName 优先使用cluster_name或者/probe的cluster参数，没有配置时使用排序后的成员地址的hash，
+// 这样同一个集群的所有成员得到相同的cluster标签，成员变化时标签也会变化
+func clusterName(cluster string, members []Member) string {
+	if cluster != "" {
+		return cluster
+	}
+
+	hosts := make([]string, 0, len(members))
+	for _, member := range members {
+		hosts = append(hosts, member.Host)
+	}
+	sort.Strings(hosts)
+	sum := sha1.Sum([]byte(strings.Join(hosts, ",")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// 3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715, built on 09/04/2020 12:44 GMT => 3.6.2
+func shortVersion(version string) string {
+	version = strings.TrimSpace(strings.Split(version, ",")[0])
+	return strings.Split(version, "-")[0]
+}
+
+// ensembleMembers 从host的conf中获取集群的所有成员，conf中没有membership时返回errNoMembership
+func ensembleMembers(host string) ([]Member, error) {
+	zkConfData, err := getStatsInfo(host, makeConfStatsInfo, "conf")
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Transport == transportAdmin {
+		// AdminServer的configuration中没有server.N，membership来自voting_view
+		view, err := getStatsInfo(host, makeConfStatsInfo, "voting_view")
+		if err != nil {
+			return nil, err
+		}
+		for _, conf := range zkConfData {
+			for _, v := range view {
+				for key, value := range v.metrics {
+					conf.metrics[key] = value
+				}
+			}
+		}
+	}
+
+	var members []Member
+	for _, conf := range zkConfData {
+		members = append(members, confMembers(host, conf.metrics)...)
+	}
+	if len(members) == 0 {
+		return nil, errNoMembership
+	}
+
+	return members, nil
+}
+
+// probeMembers 返回需要逐个探测的成员，没有membership时只探测host自身
+func probeMembers(host string) ([]Member, error) {
+	members, err := ensembleMembers(host)
+	if err == errNoMembership {
+		return []Member{{Host: host}}, nil
+	}
+	return members, err
+}
+
+func scrapeMember(member Member) memberState {
+	ms := memberState{Member: member}
+
+	zkMntrData, err := getStatsInfo(member.Host, makeMntrStatsInfo, "mntr")
+	if err != nil {
+		log.WithError(err).WithField("member", member.Host).Warn("retrieving ensemble member failed")
+		return ms
+	}
+
+	for _, mntr := range zkMntrData {
+		ms.up = true
+		ms.state = mntr.metrics["zk_server_state"]
+		ms.version = shortVersion(mntr.metrics["zk_version"])
+		if v, err := parseValue(mntr.metrics["zk_synced_followers"]); err == nil {
+			ms.synced = v
+		}
+	}
+
+	return ms
+}
+
+func (e exporterEnsemble) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	cluster := ""
+	if c, ok := ctx.Value(clusterID).(string); ok {
+		cluster = c
+	}
+
+	members, err := ensembleMembers(host)
+	if err == errNoMembership {
+		// 只看到自己时无法判断quorum和leader，不导出集群级别的指标
+		log.WithField("host", host).Debug("no ensemble membership, skipping ensemble metrics")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.WithField("members", members).Debug("ensemble members")
+
+	states := make([]memberState, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member Member) {
+			defer wg.Done()
+			states[i] = scrapeMember(member)
+		}(i, member)
+	}
+	wg.Wait()
+
+	cluster = clusterName(cluster, members)
+	emit := func(key string, value float64, labelValues ...string) {
+		if ch != nil {
+			ch <- mustNewConstMetric(e.ensembleDesc[key], prometheus.GaugeValue, value, ensembleLabelValues(cluster, labelValues...)...)
+		}
+	}
+
+	total := make(map[string]float64)
+	alive := make(map[string]float64)
+	versions := make(map[string]bool)
+	leaders := 0.0
+	synced := -1.0
+	for _, ms := range states {
+		total[ms.Role]++
+
+		up := 0.0
+		if ms.up {
+			up = 1
+			alive[ms.Role]++
+			versions[ms.version] = true
+			emit("member_info", 1, ms.Host, ms.Role, ms.state, ms.version)
+		}
+		emit("member_up", up, ms.Host, ms.Role)
+
+		if ms.state == "leader" {
+			leaders++
+			synced = ms.synced
+		}
+	}
+
+	for role, n := range total {
+		emit("members", n, role)
+		emit("members_alive", alive[role], role)
+	}
+
+	participants := total["participant"]
+	quorum := float64(int(participants)/2 + 1)
+	hasQuorum := 0.0
+	if alive["participant"] >= quorum {
+		hasQuorum = 1
+	}
+	hasLeader := 0.0
+	if leaders > 0 {
+		hasLeader = 1
+	}
+
+	emit("quorum_size", quorum)
+	emit("has_quorum", hasQuorum)
+	emit("has_leader", hasLeader)
+	emit("leaders", leaders)
+	emit("versions", float64(len(versions)))
+	if leaders == 1 && synced >= 0 && participants > 1 {
+		emit("synced_followers_ratio", synced/(participants-1))
+	}
+
+	return nil
+}
+
+func (e exporterEnsemble) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.ensembleDesc {
+		ch <- desc
+	}
+
+}
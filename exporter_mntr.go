@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 func init() {
@@ -13,34 +15,364 @@ func init() {
 }
 
 type exporterMntr struct {
-	mntrGauge		map[string]*prometheus.GaugeVec
+	mntrDescMu		*sync.Mutex
+	mntrDesc		map[string]*prometheus.Desc
+	summaryDescs	map[string]*prometheus.Desc
+	mntrCounter		map[string]*prometheus.CounterVec
+	counterTracker	*counterTracker
+	stateGauge		*prometheus.GaugeVec
+	stateDuration	*prometheus.GaugeVec
+	stateTracker	*serverStateTracker
+}
+
+// serverStates 是zk_server_state所有可能的取值，每个取值对应server_state的一个series
+var serverStates = []string{"leader", "follower", "observer", "standalone", "read-only"}
+
+// serverStateTracker 记录每个node当前的状态以及进入该状态的时间
+type serverStateTracker struct {
+	mutex sync.Mutex
+	state map[string]string
+	since map[string]time.Time
+}
+
+func newServerStateTracker() *serverStateTracker {
+	return &serverStateTracker{
+		state: make(map[string]string),
+		since: make(map[string]time.Time),
+	}
+}
+
+// current returns the last observed state of node, empty if none
+func (t *serverStateTracker) current(node string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.state[node]
+}
+
+// observe records the state of node and returns how long node has been in it.
+// The first observation starts counting from now as the real transition time is unknown.
+func (t *serverStateTracker) observe(node string, state string) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if t.state[node] != state {
+		t.state[node] = state
+		t.since[node] = now
+	}
+
+	return now.Sub(t.since[node])
+}
+
+//mntrMetric overrides how a mntr key is exported. Keys without override are exported
+//as gauges named after the sanitized key with config.MntrPrefix.
+type mntrMetric struct {
+	name      string
+	help      string
+	valueType prometheus.ValueType
+	unit      metricUnit
+}
+
+var mntrMetrics = map[string]mntrMetric{
+	"zk_num_alive_connections":            {name: "connections", help: "the number of connections."},
+	"zk_min_latency":                      {name: "min_latency", help: "Minimum Latency.", unit: unitMilliseconds},
+	"zk_avg_latency":                      {name: "avg_latency", help: "Average Latency.", unit: unitMilliseconds},
+	"zk_max_latency":                      {name: "max_latency", help: "Maximum Latency.", unit: unitMilliseconds},
+	"zk_open_file_descriptor_count":       {name: "open_file_descriptor_count", help: "Number of open file descriptors."},
+	"zk_max_file_descriptor_count":        {name: "max_file_descriptor_count", help: "Maximum number of file descriptors."},
+	"zk_outstanding_requests":             {name: "outstanding_requests", help: "Stacked requests."},
+	"zk_approximate_data_size":            {name: "approximate_data_size", help: "Data size.", unit: unitBytes},
+	"zk_packets_sent":                     {name: "packets_sent", help: "Number of packets sent."},
+	"zk_packets_received":                 {name: "packets_received", help: "Number of packets received."},
+	"zk_followers":                        {name: "followers", help: "Number of follower(Only leader have)."},
+	"zk_synced_followers":                 {name: "synced_followers", help: "Number of synchronized follower(Only leader have)."},
+	"zk_pending_syncs":                    {name: "pending_syncs", help: "Number of ready to sync."},
+	"zk_last_proposal_size":               {name: "last_proposal_size", help: "The size of the last Proposal message.", unit: unitBytes},
+	"zk_max_proposal_size":                {name: "max_proposal_size", help: "The size of the maximum Proposal message.", unit: unitBytes},
+	"zk_min_proposal_size":                {name: "min_proposal_size", help: "The size of the minimum Proposal message.", unit: unitBytes},
+	"zk_cnt_node_changed_watch_count":     {name: "cnt_node_changed_watch_count", help: "the changed watch count"},
+}
+
+// mntrValueTypes 是mntr中单调递增的key，这些key以counter导出，其余key默认为gauge
+var mntrValueTypes = map[string]prometheus.ValueType{
+	"zk_packets_sent":                                    prometheus.CounterValue,
+	"zk_packets_received":                                prometheus.CounterValue,
+	"zk_bytes_received_count":                            prometheus.CounterValue,
+	"zk_commit_count":                                    prometheus.CounterValue,
+	"zk_connection_drop_count":                           prometheus.CounterValue,
+	"zk_connection_rejected":                             prometheus.CounterValue,
+	"zk_connection_request_count":                        prometheus.CounterValue,
+	"zk_connection_revalidate_count":                     prometheus.CounterValue,
+	"zk_dead_watchers_cleared":                           prometheus.CounterValue,
+	"zk_dead_watchers_queued":                            prometheus.CounterValue,
+	"zk_diff_count":                                      prometheus.CounterValue,
+	"zk_digest_mismatches_count":                         prometheus.CounterValue,
+	"zk_ensemble_auth_fail":                              prometheus.CounterValue,
+	"zk_ensemble_auth_skip":                              prometheus.CounterValue,
+	"zk_ensemble_auth_success":                           prometheus.CounterValue,
+	"zk_learner_commit_received_count":                   prometheus.CounterValue,
+	"zk_learner_proposal_received_count":                 prometheus.CounterValue,
+	"zk_looking_count":                                   prometheus.CounterValue,
+	"zk_outstanding_changes_removed":                     prometheus.CounterValue,
+	"zk_prep_processor_request_queued":                   prometheus.CounterValue,
+	"zk_quit_leading_due_to_disloyal_voter":              prometheus.CounterValue,
+	"zk_request_throttle_wait_count":                     prometheus.CounterValue,
+	"zk_response_packet_cache_hits":                      prometheus.CounterValue,
+	"zk_response_packet_cache_misses":                    prometheus.CounterValue,
+	"zk_response_packet_get_children_cache_hits":         prometheus.CounterValue,
+	"zk_response_packet_get_children_cache_misses":       prometheus.CounterValue,
+	"zk_revalidate_count":                                prometheus.CounterValue,
+	"zk_sessionless_connections_expired":                 prometheus.CounterValue,
+	"zk_snap_count":                                      prometheus.CounterValue,
+	"zk_stale_replies":                                   prometheus.CounterValue,
+	"zk_stale_requests":                                  prometheus.CounterValue,
+	"zk_stale_requests_dropped":                          prometheus.CounterValue,
+	"zk_stale_sessions_expired":                          prometheus.CounterValue,
+	"zk_sync_processor_request_queued":                   prometheus.CounterValue,
+	"zk_tls_handshake_exceeded":                          prometheus.CounterValue,
+	"zk_unrecoverable_error_count":                       prometheus.CounterValue,
+	"zk_unsuccessful_handshake":                          prometheus.CounterValue,
+}
+
+// counterTracker 记录每个counter上一次的原始值，用于把mntr的原始值转换为CounterVec的增量
+type counterTracker struct {
+	mutex sync.Mutex
+	last  map[string]float64
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{
+		last: make(map[string]float64),
+	}
+}
+
+// delta returns how much the counter identified by id grew since the last observation.
+// A value lower than the last one means zookeeper restarted and counts from zero again,
+// so the whole value is the increase.
+func (t *counterTracker) delta(id string, value float64) float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	last, ok := t.last[id]
+	t.last[id] = value
+	if !ok || value < last {
+		return value
+	}
+	return value - last
+}
+
+// mntrUnitFor 根据命名推断mntr中没有配置的key的单位，3.6+的ServerMetrics中以latency、time或_ms结尾的
+// 指标都是毫秒，例如readlatency、fsynctime、jvm_pause_time_ms，返回去掉_ms后缀的名字
+func mntrUnitFor(name string) (string, metricUnit) {
+	if config.LegacyMetricNames {
+		return name, unitNone
+	}
+
+	switch {
+	case strings.HasSuffix(name, "_ms"):
+		return strings.TrimSuffix(name, "_ms"), unitMilliseconds
+	case strings.HasSuffix(name, "latency"), strings.HasSuffix(name, "time"):
+		return name, unitMilliseconds
+	}
+	return name, unitNone
+}
+
+// mntrMetricFor 返回key的导出方式，没有在mntrMetrics中配置的key使用默认的名字、推断的单位和gauge类型
+func mntrMetricFor(key string) mntrMetric {
+	m, ok := mntrMetrics[key]
+	if !ok {
+		name, unit := mntrUnitFor(sanitizeMetricName(strings.TrimPrefix(key, "zk_")))
+		m = mntrMetric{
+			name: config.MntrPrefix + name,
+			help: "Value of " + key + " reported by mntr.",
+			unit: unit,
+		}
+	}
+	if m.valueType == 0 {
+		m.valueType = prometheus.GaugeValue
+		if t, ok := mntrValueTypes[key]; ok {
+			m.valueType = t
+		}
+	}
+	m.name = unitMetricName(m.name, m.unit)
+	// counter按照prometheus的命名规范以_total结尾，config.LegacyMetricNames为true时保持原来的名字
+	if m.valueType == prometheus.CounterValue && !config.LegacyMetricNames && !strings.HasSuffix(m.name, "_total") {
+		m.name += "_total"
+	}
+	return m
 }
 
 func newExporterMntr() Exporter {
-	mntrGaugeVecActual := map[string]*prometheus.GaugeVec{
-		"zk_num_alive_connections":            newGaugeVec("connections", "the number of connections.", "node"),
-		"zk_server_state":					   newGaugeVec("server_is_leader", "server mode(follower/leader).", "node"),
-		"zk_min_latency":					   newGaugeVec("min_latency", "Minimum Latency.", "node"),
-		"zk_avg_latency":				       newGaugeVec("avg_latency", "Average Latency.", "node"),
-		"zk_max_latency":                      newGaugeVec("max_latency", "Maximum Latency.", "node"),
-		"zk_open_file_descriptor_count":	   newGaugeVec("open_file_descriptor_count", "Number of open file descriptors.", "node"),
-		"zk_max_file_descriptor_count":		   newGaugeVec("max_file_descriptor_count", "Maximum number of file descriptors.", "node"),
-		"zk_outstanding_requests":			   newGaugeVec("outstanding_requests", "Stacked requests.", "node"),
-		"zk_approximate_data_size":			   newGaugeVec("approximate_data_size", "Data size.", "node"),
-		"zk_packets_sent":					   newGaugeVec("packets_sent", "Number of packets sent.", "node"),
-		"zk_packets_received": 				   newGaugeVec("packets_received", "Number of packets received.", "node"),
-		"zk_followers":						   newGaugeVec("followers", "Number of follower(Only leader have).", "node"),
-		"zk_synced_followers":				   newGaugeVec("synced_followers", "Number of synchronized follower(Only leader have).", "node"),
-		"zk_pending_syncs":					   newGaugeVec("pending_syncs", "Number of ready to sync.", "node"),
-		"zk_last_proposal_size":			   newGaugeVec("last_proposal_size", "The size of the last Proposal message.", "node"),
-		"zk_max_proposal_size":				   newGaugeVec("max_proposal_size", "The size of the maximum Proposal message.", "node"),
-		"zk_min_proposal_size":				   newGaugeVec("min_proposal_size", "The size of the minimum Proposal message.", "node"),
-		"zk_cnt_node_changed_watch_count":     newGaugeVec("cnt_node_changed_watch_count", "the changed watch count", "node"),
+	mntrDescActual := make(map[string]*prometheus.Desc)
+	mntrCounterVecActual := make(map[string]*prometheus.CounterVec)
+	for key := range mntrMetrics {
+		m := mntrMetricFor(key)
+		if m.valueType == prometheus.CounterValue {
+			mntrCounterVecActual[key] = newCounterVec(m.name, m.help, "node")
+		} else {
+			mntrDescActual[key] = newDesc(m.name, m.help, "node")
+		}
 	}
 
 	return &exporterMntr{
-		mntrGauge: mntrGaugeVecActual,
+		mntrDescMu:     &sync.Mutex{},
+		mntrDesc:       mntrDescActual,
+		summaryDescs:   make(map[string]*prometheus.Desc),
+		mntrCounter:    mntrCounterVecActual,
+		counterTracker: newCounterTracker(),
+		stateGauge:     newGaugeVec("server_state", "Server state(leader/follower/observer/standalone/read-only), 1 for the current state.", "node", "state"),
+		stateDuration:  newGaugeVec("server_state_duration_seconds", "Seconds the server has been in its current state.", "node"),
+		stateTracker:   newServerStateTracker(),
+	}
+}
+
+// desc 返回key对应的Desc，第一次遇到的key会被创建并缓存
+func (e exporterMntr) desc(key string) *prometheus.Desc {
+	e.mntrDescMu.Lock()
+	defer e.mntrDescMu.Unlock()
+
+	if desc, ok := e.mntrDesc[key]; ok {
+		return desc
+	}
+
+	m := mntrMetricFor(key)
+	desc := newDesc(m.name, m.help, "node")
+	e.mntrDesc[key] = desc
+	return desc
+}
+
+// counter 返回key对应的CounterVec，第一次遇到的key会被创建并缓存
+func (e exporterMntr) counter(key string) *prometheus.CounterVec {
+	e.mntrDescMu.Lock()
+	defer e.mntrDescMu.Unlock()
+
+	if counter, ok := e.mntrCounter[key]; ok {
+		return counter
+	}
+
+	m := mntrMetricFor(key)
+	counter := newCounterVec(m.name, m.help, "node")
+	e.mntrCounter[key] = counter
+	return counter
+}
+
+// summaryDesc 返回summary family对应的Desc，第一次遇到的family会被创建并缓存
+func (e exporterMntr) summaryDesc(family string) *prometheus.Desc {
+	e.mntrDescMu.Lock()
+	defer e.mntrDescMu.Unlock()
+
+	if desc, ok := e.summaryDescs[family]; ok {
+		return desc
+	}
+
+	name, unit := mntrUnitFor(sanitizeMetricName(family))
+	desc := newDesc(config.MntrPrefix+unitMetricName(name, unit), "Summary of "+family+" reported by mntr.", "node")
+	e.summaryDescs[family] = desc
+	return desc
+}
+
+//mntrSummary presents a family of 3.6+ mntr keys sharing the same base name
+type mntrSummary struct {
+	count     uint64
+	sum       float64
+	avg       float64
+	hasCount  bool
+	hasSum    bool
+	hasAvg    bool
+	quantiles map[float64]float64
+	keys      []string
+}
+
+// 3.6+的mntr中summary类型的指标有两种写法:
+//   zk_avg_xxx / zk_min_xxx / zk_max_xxx / zk_cnt_xxx / zk_sum_xxx / zk_p50_xxx / zk_p95_xxx / zk_p99_xxx / zk_p999_xxx
+//   zk_xxx_p50 / zk_xxx_p95 / zk_xxx_p99 / zk_xxx_p999 / zk_xxx_count / zk_xxx_sum / zk_xxx_min / zk_xxx_max / zk_xxx_avg
+var (
+	mntrSummaryPrefixes = map[string]string{"avg_": "avg", "min_": "min", "max_": "max", "cnt_": "count", "sum_": "sum", "p50_": "p50", "p95_": "p95", "p99_": "p99", "p999_": "p999"}
+	mntrSummarySuffixes = map[string]string{"_p50": "p50", "_p95": "p95", "_p99": "p99", "_p999": "p999", "_count": "count", "_sum": "sum", "_min": "min", "_max": "max", "_avg": "avg"}
+	mntrSummaryQuantile = map[string]float64{"min": 0, "p50": 0.5, "p95": 0.95, "p99": 0.99, "p999": 0.999, "max": 1}
+)
+
+type mntrSummaryPart struct {
+	family string
+	part   string
+}
+
+func mntrSummaryParts(key string) []mntrSummaryPart {
+	var parts []mntrSummaryPart
+
+	name := strings.TrimPrefix(key, "zk_")
+	for prefix, part := range mntrSummaryPrefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			parts = append(parts, mntrSummaryPart{family: strings.TrimPrefix(name, prefix), part: part})
+		}
+	}
+	for suffix, part := range mntrSummarySuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			parts = append(parts, mntrSummaryPart{family: strings.TrimSuffix(name, suffix), part: part})
+		}
 	}
+
+	return parts
+}
+
+// groupMntrSummaries 将同一个family的key合并为一个summary，只有同时包含count以及sum/avg/分位数的family才会被合并，
+// 返回合并后的summary以及被合并的key。在mntrMetrics中配置过的key不参与合并。
+func groupMntrSummaries(metrics MetricMap) (map[string]*mntrSummary, map[string]bool) {
+	candidates := make(map[string]*mntrSummary)
+	keyParts := make(map[string][]mntrSummaryPart)
+
+	for key, value := range metrics {
+		if _, ok := mntrMetrics[key]; ok {
+			continue
+		}
+
+		v, err := parseValue(value)
+		if err != nil {
+			continue
+		}
+
+		for _, p := range mntrSummaryParts(key) {
+			summary, ok := candidates[p.family]
+			if !ok {
+				summary = &mntrSummary{quantiles: make(map[float64]float64)}
+				candidates[p.family] = summary
+			}
+
+			switch p.part {
+			case "count":
+				summary.count, summary.hasCount = uint64(v), true
+			case "sum":
+				summary.sum, summary.hasSum = v, true
+			case "avg":
+				summary.avg, summary.hasAvg = v, true
+			default:
+				summary.quantiles[mntrSummaryQuantile[p.part]] = v
+			}
+			keyParts[key] = append(keyParts[key], p)
+		}
+	}
+
+	summaries := make(map[string]*mntrSummary)
+	for family, summary := range candidates {
+		if summary.hasCount && (summary.hasSum || summary.hasAvg || len(summary.quantiles) > 0) {
+			if !summary.hasSum && summary.hasAvg {
+				summary.sum = summary.avg * float64(summary.count)
+			}
+			summaries[family] = summary
+		}
+	}
+
+	grouped := make(map[string]bool)
+	for key, parts := range keyParts {
+		for _, p := range parts {
+			if _, ok := summaries[p.family]; ok {
+				grouped[key] = true
+				break
+			}
+		}
+	}
+
+	return summaries, grouped
 }
 
 func makeMntrStatsInfo(body []byte, labels ...string) []StatsInfo {
@@ -58,17 +390,12 @@ func makeMntrStatsInfo(body []byte, labels ...string) []StatsInfo {
 		}
 
 		line = strings.Replace(line, "\t", " ", -1)
-		kv := strings.Split(line, " ")
-
-		if kv[0] == "zk_server_state" {
-			if kv[1] == "leader" {
-				kv[1] = "1"
-			} else {
-				kv[1] = "0"
-			}
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) != 2 {
+			continue
 		}
 
-		statsinfo.metrics[kv[0]] = kv[1]
+		statsinfo.metrics[kv[0]] = strings.TrimSpace(kv[1])
 	}
 
 	q = append(q, statsinfo)
@@ -82,38 +409,117 @@ func (e exporterMntr) Collect(ctx context.Context, ch chan<- prometheus.Metric)
 		node = n
 	}
 
-	zkMntrData, err := getStatsInfo(makeMntrStatsInfo, "mntr")
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkMntrData, err := getStatsInfo(host, makeMntrStatsInfo, "mntr")
 	if err != nil {
 		return err
 	}
 
 	log.WithField("mntrData", zkMntrData).Debug("mntr data")
 
-	for key, gauge := range e.mntrGauge {
-		for _, mntr := range zkMntrData {
-			if value, ok := mntr.metrics[key]; ok {
-				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set mntr metric for key")
-				v, err := strconv.ParseFloat(value, 64)
-				if err != nil {
-					log.WithFields(log.Fields{"key": key, "value": value}).Error("conv value to float64 failed")
-					continue
+	for _, mntr := range zkMntrData {
+		// 不在服务状态时mntr只返回 This ZooKeeper instance is not currently serving requests
+		if _, ok := mntr.metrics["zk_server_state"]; !ok {
+			return errors.New("zookeeper is not currently serving requests")
+		}
+	}
+
+	for _, mntr := range zkMntrData {
+		summaries, grouped := groupMntrSummaries(mntr.metrics)
+		for family, summary := range summaries {
+			log.WithFields(log.Fields{"family": family, "summary": summary}).Debug("Set mntr summary for family")
+			if ch != nil {
+				_, unit := mntrUnitFor(sanitizeMetricName(family))
+				quantiles := make(map[float64]float64, len(summary.quantiles))
+				for q, v := range summary.quantiles {
+					quantiles[q] = unitValue(v, unit)
 				}
-				gaugeVecWithLabelValues(gauge, node).Set(v)
+				ch <- mustNewConstSummary(e.summaryDesc(family), summary.count, unitValue(summary.sum, unit), quantiles, node)
+			}
+		}
+
+		for key, value := range mntr.metrics {
+			if key == "zk_server_state" || grouped[key] {
+				continue
+			}
+
+			v, err := parseValue(value)
+			if err != nil {
+				// zk_version之类的非数值key
+				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Skip non-numeric mntr key")
+				continue
+			}
+
+			log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set mntr metric for key")
+			m := mntrMetricFor(key)
+			if m.valueType == prometheus.CounterValue {
+				counterVecWithLabelValues(e.counter(key), node).Add(e.counterTracker.delta(node+"/"+key, unitValue(v, m.unit)))
+				continue
+			}
+			if ch != nil {
+				ch <- mustNewConstMetric(e.desc(key), m.valueType, unitValue(v, m.unit), node)
 			}
 		}
 	}
 
+	for _, mntr := range zkMntrData {
+		if state, ok := mntr.metrics["zk_server_state"]; ok {
+			e.setServerState(node, state)
+		}
+	}
+
 	if ch != nil {
-		for _, gauge := range e.mntrGauge {
-			gauge.Collect(ch)
+		e.mntrDescMu.Lock()
+		for _, counter := range e.mntrCounter {
+			counter.Collect(ch)
 		}
+		e.mntrDescMu.Unlock()
+		e.stateGauge.Collect(ch)
+		e.stateDuration.Collect(ch)
 	}
 	return nil
 }
 
+func (e exporterMntr) setServerState(node string, state string) {
+	// 之前的状态不在serverStates中时，它的series不会在下面被重置
+	if previous := e.stateTracker.current(node); previous != "" && previous != state {
+		gaugeVecWithLabelValues(e.stateGauge, node, previous).Set(0)
+	}
+
+	known := false
+	for _, s := range serverStates {
+		v := 0.0
+		if s == state {
+			v = 1
+			known = true
+		}
+		gaugeVecWithLabelValues(e.stateGauge, node, s).Set(v)
+	}
+	if !known {
+		log.WithField("state", state).Warn("unknown zookeeper server state")
+		gaugeVecWithLabelValues(e.stateGauge, node, state).Set(1)
+	}
+
+	gaugeVecWithLabelValues(e.stateDuration, node).Set(e.stateTracker.observe(node, state).Seconds())
+}
+
 func (e exporterMntr) Describe(ch chan<- *prometheus.Desc) {
-	for _, gauge := range e.mntrGauge {
-		gauge.Describe(ch)
+	e.mntrDescMu.Lock()
+	for _, desc := range e.mntrDesc {
+		ch <- desc
+	}
+	for _, desc := range e.summaryDescs {
+		ch <- desc
+	}
+	for _, counter := range e.mntrCounter {
+		counter.Describe(ch)
 	}
+	e.mntrDescMu.Unlock()
+	e.stateGauge.Describe(ch)
+	e.stateDuration.Describe(ch)
 
 }
\ No newline at end of file
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSetServerState(t *testing.T) {
+	e := newExporterMntr().(*exporterMntr)
+
+	for _, tc := range []struct {
+		state string
+		want  map[string]float64
+	}{
+		{"leader", map[string]float64{"leader": 1, "follower": 0}},
+		{"looking", map[string]float64{"leader": 0, "looking": 1}},
+		{"follower", map[string]float64{"follower": 1, "looking": 0}},
+	} {
+		e.setServerState("n", tc.state)
+		for state, want := range tc.want {
+			if got := testutil.ToFloat64(e.stateGauge.WithLabelValues("n", state)); got != want {
+				t.Errorf("after %s: server_state{state=%q} = %v, want %v", tc.state, state, got, want)
+			}
+		}
+	}
+}
+
+func TestMntrNotServing(t *testing.T) {
+	stats := makeMntrStatsInfo([]byte("This ZooKeeper instance is not currently serving requests\n"))
+	if _, ok := stats[0].metrics["zk_server_state"]; ok {
+		t.Errorf("not serving reply parsed as %v", stats[0].metrics)
+	}
+}
+
+func TestGroupMntrSummaries(t *testing.T) {
+	metrics := MetricMap{
+		// 3.6的前缀写法
+		"zk_avg_read_commit_proc_req_queued": "0.5",
+		"zk_min_read_commit_proc_req_queued": "0",
+		"zk_max_read_commit_proc_req_queued": "1",
+		"zk_cnt_read_commit_proc_req_queued": "4",
+		"zk_sum_read_commit_proc_req_queued": "2",
+		// 后缀写法，没有sum时使用avg*count
+		"zk_readlatency_count": "10",
+		"zk_readlatency_avg":   "1.5",
+		"zk_readlatency_p50":   "1",
+		"zk_readlatency_p99":   "4",
+		// AvgMinMaxPercentileCounter的分位数前缀写法
+		"zk_cnt_updatelatency":  "2",
+		"zk_sum_updatelatency":  "6",
+		"zk_p50_updatelatency":  "2",
+		"zk_p999_updatelatency": "5",
+		// 没有count的family不合并
+		"zk_fsynctime_sum": "30",
+		// 在mntrMetrics中配置过的key不参与合并
+		"zk_avg_latency":                  "0.5",
+		"zk_max_latency":                  "12",
+		"zk_min_latency":                  "0",
+		"zk_cnt_node_changed_watch_count": "0",
+		"zk_version":                      "3.6.2",
+	}
+
+	summaries, grouped := groupMntrSummaries(metrics)
+
+	want := map[string]*mntrSummary{
+		"read_commit_proc_req_queued": {count: 4, sum: 2, quantiles: map[float64]float64{0: 0, 1: 1}},
+		"readlatency":                 {count: 10, sum: 15, quantiles: map[float64]float64{0.5: 1, 0.99: 4}},
+		"updatelatency":               {count: 2, sum: 6, quantiles: map[float64]float64{0.5: 2, 0.999: 5}},
+	}
+	if len(summaries) != len(want) {
+		t.Fatalf("got families %v, want %v", keysOf(summaries), keysOf(want))
+	}
+	for family, w := range want {
+		got, ok := summaries[family]
+		if !ok {
+			t.Errorf("family %q was not grouped", family)
+			continue
+		}
+		if got.count != w.count || got.sum != w.sum || !reflect.DeepEqual(got.quantiles, w.quantiles) {
+			t.Errorf("family %q = count %d sum %v quantiles %v, want count %d sum %v quantiles %v",
+				family, got.count, got.sum, got.quantiles, w.count, w.sum, w.quantiles)
+		}
+	}
+
+	for _, key := range []string{"zk_cnt_read_commit_proc_req_queued", "zk_readlatency_p99", "zk_p50_updatelatency"} {
+		if !grouped[key] {
+			t.Errorf("%s should be grouped", key)
+		}
+	}
+	for _, key := range []string{"zk_fsynctime_sum", "zk_avg_latency", "zk_cnt_node_changed_watch_count", "zk_version"} {
+		if grouped[key] {
+			t.Errorf("%s should not be grouped", key)
+		}
+	}
+}
+
+func keysOf(m map[string]*mntrSummary) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("native", newExporterNative)
+}
+
+// exporterNative 转发3.6+内置的PrometheusMetricsProvider(默认7000端口)的指标，
+// 指标名加上namespace和config.NativePrefix，并且和其他module一样带上node和extra_labels
+type exporterNative struct{}
+
+func newExporterNative() Exporter {
+	return &exporterNative{}
+}
+
+func nativeURL(host string) string {
+	ip, _, err := net.SplitHostPort(host)
+	if err != nil {
+		ip = host
+	}
+	return "http://" + net.JoinHostPort(ip, config.NativePort) + "/metrics"
+}
+
+func scrapeNative(host string) (map[string]*dto.MetricFamily, error) {
+	client := &http.Client{Timeout: time.Duration(config.Timeout) * time.Second}
+
+	resp, err := client.Get(nativeURL(host))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics endpoint of '%s' returned %s", host, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// nativeLabels 返回原有的标签，与node或extra_labels同名的标签加上exported_前缀，和prometheus的honor_labels: false一致
+func nativeLabels(m *dto.Metric) ([]string, []string) {
+	reserved := map[string]bool{"node": true}
+	for _, name := range extraLabelNames {
+		reserved[name] = true
+	}
+
+	pairs := m.GetLabel()
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+
+	names := make([]string, 0, len(pairs))
+	values := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		name := pair.GetName()
+		if reserved[name] {
+			name = "exported_" + name
+		}
+		names = append(names, name)
+		values = append(values, pair.GetValue())
+	}
+	return names, values
+}
+
+func (e exporterNative) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	families, err := scrapeNative(host)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("families", len(families)).Debug("native metrics")
+
+	if ch == nil {
+		return nil
+	}
+
+	for name, family := range families {
+		name = config.NativePrefix + sanitizeMetricName(name)
+		for _, m := range family.GetMetric() {
+			labelNames, labelValues := nativeLabels(m)
+			desc := newDesc(name, family.GetHelp(), append([]string{"node"}, labelNames...)...)
+			labelValues = append([]string{node}, labelValues...)
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				ch <- mustNewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), labelValues...)
+			case dto.MetricType_GAUGE:
+				ch <- mustNewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), labelValues...)
+			case dto.MetricType_SUMMARY:
+				quantiles := make(map[float64]float64)
+				for _, q := range m.GetSummary().GetQuantile() {
+					quantiles[q.GetQuantile()] = q.GetValue()
+				}
+				ch <- mustNewConstSummary(desc, m.GetSummary().GetSampleCount(), m.GetSummary().GetSampleSum(), quantiles, labelValues...)
+			case dto.MetricType_HISTOGRAM:
+				buckets := make(map[float64]uint64)
+				for _, b := range m.GetHistogram().GetBucket() {
+					buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+				}
+				ch <- mustNewConstHistogram(desc, m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum(), buckets, labelValues...)
+			default:
+				ch <- mustNewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), labelValues...)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e exporterNative) Describe(ch chan<- *prometheus.Desc) {
+	// 指标由zookeeper决定，无法预先描述
+
+}
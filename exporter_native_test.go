@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestNativeLabels(t *testing.T) {
+	saved := extraLabelNames
+	extraLabelNames = []string{"env"}
+	defer func() { extraLabelNames = saved }()
+
+	for _, tc := range []struct {
+		name       string
+		body       string
+		wantNames  []string
+		wantValues []string
+	}{
+		{
+			name:       "no labels",
+			body:       "# TYPE znode_count gauge\nznode_count 5.0\n",
+			wantNames:  []string{},
+			wantValues: []string{},
+		},
+		{
+			name:       "sorted labels",
+			body:       "# TYPE jvm_memory_pool_bytes_used gauge\njvm_memory_pool_bytes_used{pool=\"Metaspace\",area=\"nonheap\"} 2.1E7\n",
+			wantNames:  []string{"area", "pool"},
+			wantValues: []string{"nonheap", "Metaspace"},
+		},
+		{
+			name:       "reserved labels",
+			body:       "# TYPE requests gauge\nrequests{node=\"zk1\",env=\"prod\",key=\"a\"} 1.0\n",
+			wantNames:  []string{"exported_env", "key", "exported_node"},
+			wantValues: []string{"prod", "a", "zk1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var parser expfmt.TextParser
+			families, err := parser.TextToMetricFamilies(strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, family := range families {
+				names, values := nativeLabels(family.GetMetric()[0])
+				if !reflect.DeepEqual(names, tc.wantNames) || !reflect.DeepEqual(values, tc.wantValues) {
+					t.Errorf("nativeLabels() = %v %v, want %v %v", names, values, tc.wantNames, tc.wantValues)
+				}
+			}
+		})
+	}
+}
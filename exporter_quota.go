@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("quota", newExporterQuota)
+}
+
+const (
+	quotaRoot      = "/zookeeper/quota"
+	quotaLimitNode = "zookeeper_limits"
+	quotaStatsNode = "zookeeper_stats"
+)
+
+type exporterQuota struct {
+	quotaDesc map[string]*prometheus.Desc
+}
+
+func newExporterQuota() Exporter {
+	quotaDescActual := map[string]*prometheus.Desc{
+		"count_limit":       newDesc("quota_count_limit", "Configured znode count quota of the path, -1 if not set.", "node", "path", "type"),
+		"bytes_limit":       newDesc(unitMetricName("quota_data_limit", unitBytes), "Configured data length quota of the path, -1 if not set.", "node", "path", "type"),
+		"count_usage":       newDesc("quota_count_usage", "Number of znodes below the quota path.", "node", "path"),
+		"bytes_usage":       newDesc(unitMetricName("quota_data_usage", unitBytes), "Data length of the znodes below the quota path.", "node", "path"),
+		"count_utilization": newDesc("quota_count_utilization_ratio", "Znode count usage divided by the count quota.", "node", "path", "type"),
+		"bytes_utilization": newDesc("quota_data_utilization_ratio", "Data length usage divided by the data length quota.", "node", "path", "type"),
+	}
+
+	return &exporterQuota{
+		quotaDesc: quotaDescActual,
+	}
+}
+
+// quota的limits和stats节点的内容格式相同，例如count=10,bytes=1000，
+// 3.7之后limits中还可能有countHardLimit和byteHardLimit
+func parseQuota(data []byte) map[string]float64 {
+	quota := make(map[string]float64)
+	for _, field := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			continue
+		}
+		quota[strings.TrimSpace(kv[0])] = v
+	}
+	return quota
+}
+
+// quotaPaths 遍历/zookeeper/quota，返回所有设置了quota的路径(去掉/zookeeper/quota前缀)
+func quotaPaths(session *zkSession) ([]string, error) {
+	var paths []string
+
+	queue := []string{quotaRoot}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		children, _, err := session.GetChildren(current)
+		if err == errNoNode {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			switch child {
+			case quotaLimitNode:
+				path := strings.TrimPrefix(current, quotaRoot)
+				if path == "" {
+					path = "/"
+				}
+				paths = append(paths, path)
+			case quotaStatsNode:
+			default:
+				queue = append(queue, childPath(current, child))
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+func (e exporterQuota) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	session, err := dialSession(host, defaultSessionTimeout)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	paths, err := quotaPaths(session)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		quotaPath := quotaRoot + path
+		if path == "/" {
+			quotaPath = quotaRoot
+		}
+
+		limitsData, _, err := session.GetData(childPath(quotaPath, quotaLimitNode))
+		if err == errNoNode {
+			// quota在遍历过程中被删除
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		statsData, _, err := session.GetData(childPath(quotaPath, quotaStatsNode))
+		if err != nil && err != errNoNode {
+			return err
+		}
+
+		limits := parseQuota(limitsData)
+		stats := parseQuota(statsData)
+
+		log.WithFields(log.Fields{"path": path, "limits": limits, "stats": stats}).Debug("quota")
+
+		if ch == nil {
+			continue
+		}
+
+		count, hasCount := stats["count"]
+		bytes, hasBytes := stats["bytes"]
+		if hasCount {
+			ch <- mustNewConstMetric(e.quotaDesc["count_usage"], prometheus.GaugeValue, count, node, path)
+		}
+		if hasBytes {
+			ch <- mustNewConstMetric(e.quotaDesc["bytes_usage"], prometheus.GaugeValue, bytes, node, path)
+		}
+
+		for _, limit := range []struct {
+			key, kind, usage string
+			value            float64
+			hasUsage         bool
+		}{
+			{"count", "soft", "count", count, hasCount},
+			{"bytes", "soft", "bytes", bytes, hasBytes},
+			{"countHardLimit", "hard", "count", count, hasCount},
+			{"byteHardLimit", "hard", "bytes", bytes, hasBytes},
+		} {
+			v, ok := limits[limit.key]
+			if !ok {
+				continue
+			}
+			ch <- mustNewConstMetric(e.quotaDesc[limit.usage+"_limit"], prometheus.GaugeValue, v, node, path, limit.kind)
+			// limit为-1表示没有设置，此时没有使用率
+			if v > 0 && limit.hasUsage {
+				ch <- mustNewConstMetric(e.quotaDesc[limit.usage+"_utilization"], prometheus.GaugeValue, limit.value/v, node, path, limit.kind)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e exporterQuota) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.quotaDesc {
+		ch <- desc
+	}
+
+}
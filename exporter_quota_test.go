@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuota(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want map[string]float64
+	}{
+		{
+			name: "soft limits",
+			data: "count=10,bytes=1000",
+			want: map[string]float64{"count": 10, "bytes": 1000},
+		},
+		{
+			name: "hard limits",
+			data: "count=-1,bytes=-1,countHardLimit=5,byteHardLimit=100",
+			want: map[string]float64{"count": -1, "bytes": -1, "countHardLimit": 5, "byteHardLimit": 100},
+		},
+		{
+			name: "stats",
+			data: "count=3,bytes=42\n",
+			want: map[string]float64{"count": 3, "bytes": 42},
+		},
+		{
+			name: "malformed fields",
+			data: "count=abc,bytes,byteHardLimit=7",
+			want: map[string]float64{"byteHardLimit": 7},
+		},
+		{
+			name: "empty",
+			data: "",
+			want: map[string]float64{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseQuota([]byte(tc.data)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseQuota(%q) = %v, want %v", tc.data, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("replication", newExporterReplication)
+}
+
+const replicationPollInterval = 10 * time.Millisecond
+
+type exporterReplication struct {
+	replicationDesc map[string]*prometheus.Desc
+}
+
+// memberLag is the outcome of polling one member for the written version
+type memberLag struct {
+	Member
+	lag      time.Duration
+	caughtUp bool
+}
+
+func newExporterReplication() Exporter {
+	replicationDescActual := map[string]*prometheus.Desc{
+		"lag":       newDesc("replication_lag_seconds", "Time between a write being acknowledged by the node and the member serving it.", "node", "member", "role"),
+		"caught_up": newDesc("replication_member_caught_up", "Did the member serve the write within the timeout, 0 if it could not be reached.", "node", "member", "role"),
+	}
+
+	return &exporterReplication{
+		replicationDesc: replicationDescActual,
+	}
+}
+
+// pollMember 在session上反复getData，直到path的版本不小于version或者超过deadline
+func pollMember(session *zkSession, path string, version int32, written time.Time, deadline time.Time) (time.Duration, bool) {
+	for {
+		_, stat, err := session.GetData(path)
+		if err == nil && stat.Version >= version {
+			return time.Since(written), true
+		}
+		if err != nil && err != errNoNode {
+			log.WithError(err).WithField("member", session.host).Debug("polling replication znode failed")
+			return 0, false
+		}
+		if time.Now().After(deadline) {
+			return 0, false
+		}
+		time.Sleep(replicationPollInterval)
+	}
+}
+
+func (e exporterReplication) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkConfData, err := getStatsInfo(host, makeConfStatsInfo, "conf")
+	if err != nil {
+		return err
+	}
+
+	all, err := ensembleMembers(host)
+	if err == errNoMembership {
+		// standalone没有其他成员
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var members []Member
+	for _, member := range all {
+		// 跳过写入的成员自身
+		if member.Host != host && member.ID != zkConfData[0].metrics["serverId"] {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	writer, err := dialSession(host, defaultSessionTimeout)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	if err := ensurePath(writer, config.CanaryPath); err != nil {
+		return err
+	}
+	// 成员使用各自的session读取，所以不能用临时节点，采集结束时删除
+	path := canaryNode(node) + "_replication"
+	if _, err := writer.Create(path, nil, probeACL(writer), flagPersistent); err != nil && err != errNodeExists {
+		return err
+	}
+	defer func() {
+		if err := writer.Delete(path, -1); err != nil && err != errNoNode {
+			log.WithError(err).WithField("path", path).Warn("deleting replication znode failed")
+		}
+	}()
+
+	// 先建立到所有成员的连接，握手的时间不计入延迟
+	lags := make([]memberLag, len(members))
+	sessions := make([]*zkSession, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		lags[i].Member = member
+		wg.Add(1)
+		go func(i int, member Member) {
+			defer wg.Done()
+			session, err := dialSession(member.Host, defaultSessionTimeout)
+			if err != nil {
+				log.WithError(err).WithField("member", member.Host).Warn("connecting to ensemble member failed")
+				return
+			}
+			sessions[i] = session
+		}(i, member)
+	}
+	wg.Wait()
+	for _, session := range sessions {
+		if session != nil {
+			defer session.Close()
+		}
+	}
+
+	stat, err := writer.SetData(path, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)), -1)
+	if err != nil {
+		return err
+	}
+	written := time.Now()
+	deadline := written.Add(time.Duration(config.Timeout) * time.Second)
+
+	for i, session := range sessions {
+		if session == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, session *zkSession) {
+			defer wg.Done()
+			lags[i].lag, lags[i].caughtUp = pollMember(session, path, stat.Version, written, deadline)
+		}(i, session)
+	}
+	wg.Wait()
+
+	log.WithField("lags", lags).Debug("replication lags")
+
+	if ch == nil {
+		return nil
+	}
+	for _, ml := range lags {
+		caughtUp := 0.0
+		if ml.caughtUp {
+			caughtUp = 1
+			ch <- mustNewConstMetric(e.replicationDesc["lag"], prometheus.GaugeValue, ml.lag.Seconds(), node, ml.Host, ml.Role)
+		}
+		ch <- mustNewConstMetric(e.replicationDesc["caught_up"], prometheus.GaugeValue, caughtUp, node, ml.Host, ml.Role)
+	}
+
+	return nil
+}
+
+func (e exporterReplication) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.replicationDesc {
+		ch <- desc
+	}
+
+}
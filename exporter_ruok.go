@@ -4,7 +4,6 @@ import (
 	"context"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
-	"strconv"
 )
 
 func init() {
@@ -51,7 +50,12 @@ func (e exporterRuok) Collect(ctx context.Context, ch chan<- prometheus.Metric)
 		node = n
 	}
 
-	zkRuokData, err := getStatsInfo(makeRuokStatsInfo, "ruok")
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkRuokData, err := getStatsInfo(host, makeRuokStatsInfo, "ruok")
 	if err != nil {
 		return err
 	}
@@ -62,7 +66,7 @@ func (e exporterRuok) Collect(ctx context.Context, ch chan<- prometheus.Metric)
 		for _, ruok := range zkRuokData {
 			if value, ok := ruok.metrics[key]; ok {
 				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set ruok metric for key")
-				v, err := strconv.ParseFloat(value, 64)
+				v, err := parseValue(value)
 				if err != nil {
 					log.WithFields(log.Fields{"key": key, "value": value}).Error("conv value to float64 failed")
 					continue
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("session", newExporterSession)
+}
+
+// exporterSession 对每个成员建立一个真实的session，collectWithDuration只统计了四字命令的耗时
+type exporterSession struct {
+	handshake         *prometheus.HistogramVec
+	negotiatedTimeout *prometheus.HistogramVec
+	ping              *prometheus.HistogramVec
+	failures          *prometheus.CounterVec
+	requestedTimeout  *prometheus.Desc
+}
+
+func newExporterSession() Exporter {
+	latencyBuckets := prometheus.ExponentialBuckets(0.0005, 2, 15)
+	timeoutBuckets := []float64{2, 4, 6, 10, 20, 30, 40, 60}
+
+	return &exporterSession{
+		handshake:         newHistogramVec("session_handshake_duration_seconds", "Time to connect to the member and establish a session.", latencyBuckets, "node", "member"),
+		negotiatedTimeout: newHistogramVec("session_negotiated_timeout_seconds", "Session timeout negotiated by the member.", timeoutBuckets, "node", "member"),
+		ping:              newHistogramVec("session_ping_duration_seconds", "Round trip time of a ping on the session.", latencyBuckets, "node", "member"),
+		failures:          newCounterVec("session_probe_failures_total", "Number of session probes that failed by stage.", "node", "member", "stage"),
+		requestedTimeout:  newDesc("session_requested_timeout_seconds", "Session timeout requested by the probe.", "node"),
+	}
+}
+
+// probeSession 建立session，ping一次后正常关闭
+func (e exporterSession) probeSession(node string, member Member) {
+	start := time.Now()
+	session, err := dialSession(member.Host, defaultSessionTimeout)
+	if err != nil {
+		log.WithError(err).WithField("member", member.Host).Warn("establishing session failed")
+		counterVecWithLabelValues(e.failures, node, member.Host, "handshake").Inc()
+		return
+	}
+	defer session.Close()
+
+	histogramVecWithLabelValues(e.handshake, node, member.Host).Observe(time.Since(start).Seconds())
+	histogramVecWithLabelValues(e.negotiatedTimeout, node, member.Host).Observe(session.timeout.Seconds())
+
+	rtt, err := session.Ping()
+	if err != nil {
+		log.WithError(err).WithField("member", member.Host).Warn("ping failed")
+		counterVecWithLabelValues(e.failures, node, member.Host, "ping").Inc()
+		return
+	}
+	histogramVecWithLabelValues(e.ping, node, member.Host).Observe(rtt.Seconds())
+}
+
+func (e exporterSession) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	members, err := probeMembers(host)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, member := range members {
+		for _, stage := range []string{"handshake", "ping"} {
+			counterVecWithLabelValues(e.failures, node, member.Host, stage)
+		}
+		wg.Add(1)
+		go func(member Member) {
+			defer wg.Done()
+			e.probeSession(node, member)
+		}(member)
+	}
+	wg.Wait()
+
+	if ch != nil {
+		e.handshake.Collect(ch)
+		e.negotiatedTimeout.Collect(ch)
+		e.ping.Collect(ch)
+		e.failures.Collect(ch)
+		ch <- mustNewConstMetric(e.requestedTimeout, prometheus.GaugeValue, defaultSessionTimeout.Seconds(), node)
+	}
+	return nil
+}
+
+func (e exporterSession) Describe(ch chan<- *prometheus.Desc) {
+	e.handshake.Describe(ch)
+	e.negotiatedTimeout.Describe(ch)
+	e.ping.Describe(ch)
+	e.failures.Describe(ch)
+	ch <- e.requestedTimeout
+
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("srvr", newExporterSrvr)
+}
+
+type exporterSrvr struct {
+	srvrGauge map[string]*prometheus.GaugeVec
+	infoDesc  *prometheus.Desc
+}
+
+var srvrUnits = map[string]metricUnit{
+	"min_latency": unitMilliseconds,
+	"avg_latency": unitMilliseconds,
+	"max_latency": unitMilliseconds,
+}
+
+func newExporterSrvr() Exporter {
+	srvrGaugeVecActual := map[string]*prometheus.GaugeVec{
+		"min_latency":  newGaugeVec(unitMetricName("srvr_min_latency", srvrUnits["min_latency"]), "Minimum Latency reported by srvr.", "node"),
+		"avg_latency":  newGaugeVec(unitMetricName("srvr_avg_latency", srvrUnits["avg_latency"]), "Average Latency reported by srvr.", "node"),
+		"max_latency":  newGaugeVec(unitMetricName("srvr_max_latency", srvrUnits["max_latency"]), "Maximum Latency reported by srvr.", "node"),
+		"received":     newGaugeVec("srvr_packets_received", "Number of packets received reported by srvr.", "node"),
+		"sent":         newGaugeVec("srvr_packets_sent", "Number of packets sent reported by srvr.", "node"),
+		"connections":  newGaugeVec("srvr_connections", "Number of connections reported by srvr.", "node"),
+		"outstanding":  newGaugeVec("srvr_outstanding_requests", "Stacked requests reported by srvr.", "node"),
+		"zxid":         newGaugeVec("srvr_zxid", "The last zxid of the server.", "node"),
+		"zxid_epoch":   newGaugeVec("srvr_zxid_epoch", "Epoch of the last zxid of the server.", "node"),
+		"zxid_counter": newGaugeVec("srvr_zxid_counter", "Counter of the last zxid of the server.", "node"),
+		"node_count":   newGaugeVec("srvr_znode_count", "Number of znodes reported by srvr.", "node"),
+	}
+
+	return &exporterSrvr{
+		srvrGauge: srvrGaugeVecActual,
+		infoDesc:  newDesc("server_info", "A metric with a constant '1' value labeled by zookeeper version and server mode.", "node", "version", "mode"),
+	}
+}
+
+// srvr的返回格式如下，stat在此基础上多了Clients部分，同样可以解析:
+//
+//	Zookeeper version: 3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715, built on 09/04/2020 12:44 GMT
+//	Latency min/avg/max: 0/0.5/12
+//	Received: 120
+//	Sent: 119
+//	Connections: 2
+//	Outstanding: 0
+//	Zxid: 0x200000005
+//	Mode: leader
+//	Node count: 5
+func makeSrvrStatsInfo(body []byte, labels ...string) []StatsInfo {
+	var q []StatsInfo
+
+	statsinfo := StatsInfo{}
+	statsinfo.labels = make(map[string]string)
+	statsinfo.metrics = make(MetricMap)
+
+	reply := string(body)
+	lines := strings.Split(reply, "\n")
+
+	for _, line := range lines {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 || strings.HasPrefix(line, " ") {
+			continue
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "Zookeeper version":
+			statsinfo.labels["version"] = shortVersion(value)
+		case "Mode":
+			statsinfo.labels["mode"] = value
+		case "Latency min/avg/max":
+			latency := strings.Split(value, "/")
+			if len(latency) == 3 {
+				statsinfo.metrics["min_latency"] = latency[0]
+				statsinfo.metrics["avg_latency"] = latency[1]
+				statsinfo.metrics["max_latency"] = latency[2]
+			}
+		case "Zxid":
+			statsinfo.metrics["zxid"] = value
+			if epoch, counter, err := splitZxid(value); err == nil {
+				statsinfo.metrics["zxid_epoch"] = strconv.FormatUint(epoch, 10)
+				statsinfo.metrics["zxid_counter"] = strconv.FormatUint(counter, 10)
+			}
+		default:
+			statsinfo.metrics[strings.Replace(strings.ToLower(key), " ", "_", -1)] = value
+		}
+	}
+
+	q = append(q, statsinfo)
+
+	return q
+}
+
+func (e exporterSrvr) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkSrvrData, err := getStatsInfo(host, makeSrvrStatsInfo, "srvr")
+	if _, ok := err.(*notWhitelistedError); ok {
+		// 只有srvr不在白名单中时才尝试stat，连接失败时重试只会再等待一次超时
+		log.WithError(err).Debug("srvr is not whitelisted, falling back to stat")
+		zkSrvrData, err = getStatsInfo(host, makeSrvrStatsInfo, "stat")
+	}
+	if err != nil {
+		return err
+	}
+
+	log.WithField("srvrData", zkSrvrData).Debug("srvr data")
+
+	for _, srvr := range zkSrvrData {
+		if srvr.labels["mode"] == "" {
+			return errors.New("zookeeper is not currently serving requests")
+		}
+	}
+
+	for key, gauge := range e.srvrGauge {
+		for _, srvr := range zkSrvrData {
+			if value, ok := srvr.metrics[key]; ok {
+				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set srvr metric for key")
+				v, err := parseValue(value)
+				if err != nil {
+					log.WithFields(log.Fields{"key": key, "value": value}).Error("conv value to float64 failed")
+					continue
+				}
+				gaugeVecWithLabelValues(gauge, node).Set(unitValue(v, srvrUnits[key]))
+			}
+		}
+	}
+
+	if ch != nil {
+		for _, gauge := range e.srvrGauge {
+			gauge.Collect(ch)
+		}
+		for _, srvr := range zkSrvrData {
+			ch <- mustNewConstMetric(e.infoDesc, prometheus.GaugeValue, 1, node, srvr.labels["version"], srvr.labels["mode"])
+		}
+	}
+	return nil
+}
+
+func (e exporterSrvr) Describe(ch chan<- *prometheus.Desc) {
+	for _, gauge := range e.srvrGauge {
+		gauge.Describe(ch)
+	}
+	ch <- e.infoDesc
+
+}
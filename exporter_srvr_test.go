@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakeSrvrStatsInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		body    string
+		labels  map[string]string
+		metrics MetricMap
+	}{
+		{
+			name: "srvr",
+			body: `Zookeeper version: 3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715, built on 09/04/2020 12:44 GMT
+Latency min/avg/max: 0/0.5/12
+Received: 120
+Sent: 119
+Connections: 2
+Outstanding: 0
+Zxid: 0x200000005
+Mode: leader
+Node count: 5
+Proposal sizes last/min/max: 32/32/48
+`,
+			labels: map[string]string{"version": "3.6.2", "mode": "leader"},
+			metrics: MetricMap{
+				"min_latency":                 "0",
+				"avg_latency":                 "0.5",
+				"max_latency":                 "12",
+				"received":                    "120",
+				"sent":                        "119",
+				"connections":                 "2",
+				"outstanding":                 "0",
+				"zxid":                        "0x200000005",
+				"zxid_epoch":                  "2",
+				"zxid_counter":                "5",
+				"node_count":                  "5",
+				"proposal_sizes_last/min/max": "32/32/48",
+			},
+		},
+		{
+			// stat多了Clients部分，以空格开头的行被忽略
+			name: "stat",
+			body: `Zookeeper version: 3.4.14-4c25d480e66aadd371de8bd2fd8da255ac140bcf, built on 03/06/2019 16:18 GMT
+Clients:
+ /127.0.0.1:56980[1](queued=0,recved=10,sent=9)
+ /127.0.0.1:56982[0](queued=0,recved=1,sent=0)
+
+Latency min/avg/max: 0/1/7
+Received: 11
+Sent: 9
+Connections: 2
+Outstanding: 0
+Zxid: 0x0
+Mode: standalone
+Node count: 4
+`,
+			labels: map[string]string{"version": "3.4.14", "mode": "standalone"},
+			metrics: MetricMap{
+				"clients":      "",
+				"min_latency":  "0",
+				"avg_latency":  "1",
+				"max_latency":  "7",
+				"received":     "11",
+				"sent":         "9",
+				"connections":  "2",
+				"outstanding":  "0",
+				"zxid":         "0x0",
+				"zxid_epoch":   "0",
+				"zxid_counter": "0",
+				"node_count":   "4",
+			},
+		},
+		{
+			name:    "not serving",
+			body:    "This ZooKeeper instance is not currently serving requests\n",
+			labels:  map[string]string{},
+			metrics: MetricMap{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := makeSrvrStatsInfo([]byte(tc.body))
+			if len(stats) != 1 {
+				t.Fatalf("got %d stats, want 1", len(stats))
+			}
+			if !reflect.DeepEqual(stats[0].labels, tc.labels) {
+				t.Errorf("labels = %v, want %v", stats[0].labels, tc.labels)
+			}
+			if !reflect.DeepEqual(stats[0].metrics, tc.metrics) {
+				t.Errorf("metrics = %v, want %v", stats[0].metrics, tc.metrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	RegisterExporter("subtree", newExporterSubtree)
+}
+
+type exporterSubtree struct {
+	subtreeDesc map[string]*prometheus.Desc
+}
+
+func newExporterSubtree() Exporter {
+	subtreeDescActual := map[string]*prometheus.Desc{
+		"nodes":        newDesc("znode_subtree_nodes", "Number of znodes below the prefix, including the prefix itself.", "node", "prefix"),
+		"children":     newDesc("znode_subtree_children", "Sum of the children counts of the znodes below the prefix.", "node", "prefix"),
+		"bytes":        newDesc(unitMetricName("znode_subtree_data", unitBytes), "Total data length of the znodes below the prefix.", "node", "prefix"),
+		"visited":      newDesc("znode_crawl_visited_nodes", "Number of znodes visited by the last crawl.", "node"),
+		"denied":       newDesc("znode_crawl_denied_nodes", "Number of znodes whose children the last crawl was not allowed to list.", "node"),
+		"truncated":    newDesc("znode_crawl_truncated", "Did the last crawl stop at crawler.max_nodes.", "node"),
+		"duration":     newDesc("znode_crawl_duration_seconds", "Duration of the last successful crawl.", "node"),
+		"last_success": newDesc("znode_crawl_last_success_timestamp_seconds", "Unix timestamp of the last successful crawl.", "node"),
+	}
+
+	return &exporterSubtree{
+		subtreeDesc: subtreeDescActual,
+	}
+}
+
+func (e exporterSubtree) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	// 遍历在后台按自己的周期进行，这里只导出最近一次成功的结果
+	result, err := getCrawler(host).Result()
+	if result == nil || ch == nil {
+		return err
+	}
+
+	truncated := 0.0
+	if result.truncated {
+		truncated = 1
+	}
+
+	for prefix, stats := range result.prefixes {
+		ch <- mustNewConstMetric(e.subtreeDesc["nodes"], prometheus.GaugeValue, stats.nodes, node, prefix)
+		ch <- mustNewConstMetric(e.subtreeDesc["children"], prometheus.GaugeValue, stats.children, node, prefix)
+		ch <- mustNewConstMetric(e.subtreeDesc["bytes"], prometheus.GaugeValue, stats.bytes, node, prefix)
+	}
+	ch <- mustNewConstMetric(e.subtreeDesc["visited"], prometheus.GaugeValue, float64(result.visited), node)
+	ch <- mustNewConstMetric(e.subtreeDesc["denied"], prometheus.GaugeValue, float64(result.denied), node)
+	ch <- mustNewConstMetric(e.subtreeDesc["truncated"], prometheus.GaugeValue, truncated, node)
+	ch <- mustNewConstMetric(e.subtreeDesc["duration"], prometheus.GaugeValue, result.duration.Seconds(), node)
+	ch <- mustNewConstMetric(e.subtreeDesc["last_success"], prometheus.GaugeValue, float64(result.finished.UnixNano())/float64(time.Second), node)
+
+	return err
+}
+
+func (e exporterSubtree) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.subtreeDesc {
+		ch <- desc
+	}
+
+}
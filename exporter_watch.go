@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("watch", newExporterWatch)
+}
+
+// 在成员上设置watch的阶段，失败时无法得到通知延迟
+var watchSetupStages = []string{"connect", "sync", "watch"}
+
+type exporterWatch struct {
+	latency  *prometheus.HistogramVec
+	missed   *prometheus.CounterVec
+	failures *prometheus.CounterVec
+}
+
+func newExporterWatch() Exporter {
+	return &exporterWatch{
+		latency:  newHistogramVec("watch_notification_duration_seconds", "Time between modifying a znode and the member delivering the watch notification.", prometheus.ExponentialBuckets(0.0005, 2, 15), "node", "member"),
+		missed:   newCounterVec("watch_notifications_missed_total", "Number of watch notifications the member did not deliver within the timeout.", "node", "member"),
+		failures: newCounterVec("watch_probe_failures_total", "Number of watch probes that failed before the znode was modified by stage.", "node", "member", "stage"),
+	}
+}
+
+// waitEvent 等待path上的数据变更通知，返回是否在deadline之前收到。
+// watch是在节点存在时设置的，NodeCreated等其他事件不是这次修改触发的
+func waitEvent(session *zkSession, path string, deadline time.Time) bool {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for {
+		select {
+		case event := <-session.Events():
+			if event.Path == path && event.Type == eventNodeDataChanged {
+				return true
+			}
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+func (e exporterWatch) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	if ch != nil {
+		defer func() {
+			e.latency.Collect(ch)
+			e.missed.Collect(ch)
+			e.failures.Collect(ch)
+		}()
+	}
+
+	members, err := probeMembers(host)
+	if err != nil {
+		return err
+	}
+
+	writer, err := dialSession(host, defaultSessionTimeout)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	if err := ensurePath(writer, config.CanaryPath); err != nil {
+		return err
+	}
+	// 成员的session在这个节点上设置watch，所以不能用临时节点，采集结束时删除
+	path := canaryNode(node) + "_watch"
+	if _, err := writer.Create(path, nil, probeACL(writer), flagPersistent); err != nil && err != errNodeExists {
+		return err
+	}
+	defer func() {
+		if err := writer.Delete(path, -1); err != nil && err != errNoNode {
+			log.WithError(err).WithField("path", path).Warn("deleting watch znode failed")
+		}
+	}()
+
+	// 每个成员一个session，在修改之前设置好watch
+	watchers := make([]*zkSession, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		counterVecWithLabelValues(e.missed, node, member.Host)
+		for _, stage := range watchSetupStages {
+			counterVecWithLabelValues(e.failures, node, member.Host, stage)
+		}
+		wg.Add(1)
+		go func(i int, member Member) {
+			defer wg.Done()
+			session, err := dialSession(member.Host, defaultSessionTimeout)
+			if err != nil {
+				log.WithError(err).WithField("member", member.Host).Warn("connecting to ensemble member failed")
+				counterVecWithLabelValues(e.failures, node, member.Host, "connect").Inc()
+				return
+			}
+			// 成员可能还没有同步到writer创建的节点，先sync，否则exists的watch会在创建时触发
+			if err := session.Sync(path); err != nil {
+				log.WithError(err).WithField("member", member.Host).Warn("syncing ensemble member failed")
+				counterVecWithLabelValues(e.failures, node, member.Host, "sync").Inc()
+				session.Close()
+				return
+			}
+			stat, err := session.ExistsWatch(path)
+			if err == nil && stat == nil {
+				err = errNoNode
+			}
+			if err != nil {
+				log.WithError(err).WithField("member", member.Host).Warn("setting watch failed")
+				counterVecWithLabelValues(e.failures, node, member.Host, "watch").Inc()
+				session.Close()
+				return
+			}
+			watchers[i] = session
+		}(i, member)
+	}
+	wg.Wait()
+	for _, session := range watchers {
+		if session != nil {
+			defer session.Close()
+		}
+	}
+
+	start := time.Now()
+	if _, err := writer.SetData(path, []byte(strconv.FormatInt(start.UnixNano(), 10)), -1); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(time.Duration(config.Timeout) * time.Second)
+
+	for i, session := range watchers {
+		if session == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(member Member, session *zkSession) {
+			defer wg.Done()
+			if waitEvent(session, path, deadline) {
+				histogramVecWithLabelValues(e.latency, node, member.Host).Observe(time.Since(start).Seconds())
+			} else {
+				log.WithField("member", member.Host).Warn("watch notification missed")
+				counterVecWithLabelValues(e.missed, node, member.Host).Inc()
+			}
+		}(members[i], session)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (e exporterWatch) Describe(ch chan<- *prometheus.Desc) {
+	e.latency.Describe(ch)
+	e.missed.Describe(ch)
+	e.failures.Describe(ch)
+
+}
@@ -1 +1,110 @@
 package main
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+func init() {
+	RegisterExporter("wchs", newExporterWchs)
+}
+
+type exporterWchs struct {
+	wchsGauge		map[string]*prometheus.GaugeVec
+}
+
+func newExporterWchs() Exporter {
+	wchsGaugeVecActual := map[string]*prometheus.GaugeVec{
+		"connections":            newGaugeVec("watch_connections", "Number of connections with watches.", "node"),
+		"paths":                  newGaugeVec("watch_paths", "Number of watched paths.", "node"),
+		"watches":                newGaugeVec("watch_count", "Total number of watches.", "node"),
+	}
+
+	return &exporterWchs{
+		wchsGauge: wchsGaugeVecActual,
+	}
+}
+
+// wchs的返回格式如下:
+//   1 connections watching 1 paths
+//   Total watches:1
+func makeWchsStatsInfo(body []byte, labels ...string) []StatsInfo {
+	var q []StatsInfo
+
+	statsinfo := StatsInfo{}
+	statsinfo.metrics = make(MetricMap)
+
+	reply := string(body)
+	lines := strings.Split(reply, "\n")
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "Total watches:") {
+			statsinfo.metrics["watches"] = strings.TrimSpace(strings.TrimPrefix(line, "Total watches:"))
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 5 && fields[1] == "connections" && fields[4] == "paths" {
+			statsinfo.metrics["connections"] = fields[0]
+			statsinfo.metrics["paths"] = fields[3]
+		}
+	}
+
+	q = append(q, statsinfo)
+
+	return q
+}
+
+func (e exporterWchs) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	zkWchsData, err := getStatsInfo(host, makeWchsStatsInfo, "wchs")
+	if err != nil {
+		return err
+	}
+
+	log.WithField("wchsData", zkWchsData).Debug("wchs data")
+
+	for key, gauge := range e.wchsGauge {
+		for _, wchs := range zkWchsData {
+			if value, ok := wchs.metrics[key]; ok {
+				log.WithFields(log.Fields{"key": key, "value": value}).Debug("Set wchs metric for key")
+				v, err := parseValue(value)
+				if err != nil {
+					log.WithFields(log.Fields{"key": key, "value": value}).Error("conv value to float64 failed")
+					continue
+				}
+				gaugeVecWithLabelValues(gauge, node).Set(v)
+			}
+		}
+	}
+
+	if ch != nil {
+		for _, gauge := range e.wchsGauge {
+			gauge.Collect(ch)
+		}
+	}
+	return nil
+}
+
+func (e exporterWchs) Describe(ch chan<- *prometheus.Desc) {
+	for _, gauge := range e.wchsGauge {
+		gauge.Describe(ch)
+	}
+
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakeWchsStatsInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		want MetricMap
+	}{
+		{
+			name: "watches",
+			body: "3 connections watching 12 paths\nTotal watches:15\n",
+			want: MetricMap{"connections": "3", "paths": "12", "watches": "15"},
+		},
+		{
+			name: "no watches",
+			body: "0 connections watching 0 paths\nTotal watches:0\n",
+			want: MetricMap{"connections": "0", "paths": "0", "watches": "0"},
+		},
+		{
+			name: "not in the whitelist",
+			body: "wchs is not executed because it is not in the whitelist.\n",
+			want: MetricMap{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := makeWchsStatsInfo([]byte(tc.body))
+			if len(stats) != 1 || !reflect.DeepEqual(stats[0].metrics, tc.want) {
+				t.Errorf("makeWchsStatsInfo() = %+v, want %v", stats, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("znode", newExporterZnode)
+}
+
+type exporterZnode struct {
+	znodeDesc map[string]*prometheus.Desc
+}
+
+func newExporterZnode() Exporter {
+	znodeDescActual := map[string]*prometheus.Desc{
+		"exists":       newDesc("znode_exists", "Does the znode exist.", "node", "path"),
+		"num_children": newDesc("znode_num_children", "Number of children of the znode.", "node", "path"),
+		"data_length":  newDesc(unitMetricName("znode_data_length", unitBytes), "Length of the data of the znode.", "node", "path"),
+		"ephemeral":    newDesc("znode_ephemeral", "Is the znode ephemeral (has an ephemeral owner).", "node", "path"),
+		"version":      newDesc("znode_version", "Number of changes to the data of the znode.", "node", "path"),
+		"cversion":     newDesc("znode_cversion", "Number of changes to the children of the znode.", "node", "path"),
+		"aversion":     newDesc("znode_aversion", "Number of changes to the ACL of the znode.", "node", "path"),
+		"mtime_age":    newDesc("znode_mtime_age_seconds", "Seconds since the znode was last modified.", "node", "path"),
+	}
+
+	return &exporterZnode{
+		znodeDesc: znodeDescActual,
+	}
+}
+
+func (e exporterZnode) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	session, err := dialSession(host, defaultSessionTimeout)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	emit := func(key string, value float64, path string) {
+		if ch != nil {
+			ch <- mustNewConstMetric(e.znodeDesc[key], prometheus.GaugeValue, value, node, path)
+		}
+	}
+
+	for _, path := range config.ZnodePaths {
+		stat, err := session.Exists(path)
+		if err != nil {
+			return err
+		}
+
+		log.WithFields(log.Fields{"path": path, "stat": stat}).Debug("znode stat")
+
+		if stat == nil {
+			emit("exists", 0, path)
+			continue
+		}
+
+		ephemeral := 0.0
+		if stat.EphemeralOwner != 0 {
+			ephemeral = 1
+		}
+
+		emit("exists", 1, path)
+		emit("num_children", float64(stat.NumChildren), path)
+		emit("data_length", float64(stat.DataLength), path)
+		emit("ephemeral", ephemeral, path)
+		emit("version", float64(stat.Version), path)
+		emit("cversion", float64(stat.Cversion), path)
+		emit("aversion", float64(stat.Aversion), path)
+		emit("mtime_age", time.Since(time.Unix(0, stat.Mtime*int64(time.Millisecond))).Seconds(), path)
+	}
+
+	return nil
+}
+
+func (e exporterZnode) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.znodeDesc {
+		ch <- desc
+	}
+
+}
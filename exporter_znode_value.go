@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterExporter("znode_value", newExporterZnodeValue)
+}
+
+type exporterZnodeValue struct {
+	rules []znodeValueExtractor
+}
+
+//znodeValueExtractor is a compiled znodeValueRule
+type znodeValueExtractor struct {
+	rule        znodeValueRule
+	desc        *prometheus.Desc
+	labelValues []string
+	regex       *regexp.Regexp
+}
+
+// znodeValueNamesValid 检查指标名和标签名是否合法，标签不能和node以及extra_labels重名，
+// 不合法的名字在注册时会panic
+func znodeValueNamesValid(rule znodeValueRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if name := prometheus.BuildFQName(namespace, "", sanitizeMetricName(rule.Name)); !model.IsValidMetricName(model.LabelValue(name)) {
+		return fmt.Errorf("'%s' is not a valid metric name", name)
+	}
+	for name := range rule.Labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("'%s' is not a valid label name", name)
+		}
+		if name == "node" {
+			return fmt.Errorf("label 'node' is reserved")
+		}
+		for _, extra := range extraLabelNames {
+			if name == extra {
+				return fmt.Errorf("label '%s' is already set by extra_labels", name)
+			}
+		}
+	}
+	return nil
+}
+
+// znodeValueSeries 返回规则的非空标签，同名的规则标签相同时会导出重复的序列
+func znodeValueSeries(rule znodeValueRule) string {
+	var pairs []string
+	for name, value := range rule.Labels {
+		if value != "" {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// newExporterZnodeValue 同名的规则共用一个Desc，标签是所有规则标签的并集(规则中没有的标签值为空)，
+// help取第一个配置了help的规则。help不一致或者标签完全相同的规则会被跳过，否则注册时会panic
+func newExporterZnodeValue() Exporter {
+	var rules []znodeValueExtractor
+	helps := make(map[string]string)
+	series := make(map[string]map[string]bool)
+	labelNames := make(map[string]map[string]bool)
+
+	for _, rule := range config.ZnodeValues {
+		extractor := znodeValueExtractor{rule: rule}
+		name := sanitizeMetricName(rule.Name)
+		logger := log.WithFields(log.Fields{"path": rule.Path, "name": name})
+
+		if rule.Regex != "" {
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				logger.WithError(err).Error("invalid znode value regex, rule skipped")
+				continue
+			}
+			extractor.regex = regex
+		}
+
+		if err := znodeValueNamesValid(rule); err != nil {
+			logger.WithError(err).Error("invalid znode value metric or label name, rule skipped")
+			continue
+		}
+
+		if help, ok := helps[name]; ok && rule.Help != "" && help != "" && help != rule.Help {
+			logger.Errorf("help conflicts with the help '%s' of another rule with the same name, rule skipped", help)
+			continue
+		}
+		if series[name][znodeValueSeries(rule)] {
+			logger.Error("another rule with the same name has the same labels, rule skipped")
+			continue
+		}
+
+		if series[name] == nil {
+			series[name] = make(map[string]bool)
+			labelNames[name] = make(map[string]bool)
+		}
+		series[name][znodeValueSeries(rule)] = true
+		for label := range rule.Labels {
+			labelNames[name][label] = true
+		}
+		if helps[name] == "" {
+			helps[name] = rule.Help
+		}
+
+		rules = append(rules, extractor)
+	}
+
+	descs := make(map[string]*prometheus.Desc)
+	for i := range rules {
+		rule := rules[i].rule
+		name := sanitizeMetricName(rule.Name)
+
+		var names []string
+		for label := range labelNames[name] {
+			names = append(names, label)
+		}
+		sort.Strings(names)
+		for _, label := range names {
+			rules[i].labelValues = append(rules[i].labelValues, rule.Labels[label])
+		}
+
+		if _, ok := descs[name]; !ok {
+			help := helps[name]
+			if help == "" {
+				help = "Value of znode " + rule.Path + "."
+			}
+			descs[name] = newDesc(name, help, append([]string{"node"}, names...)...)
+		}
+		rules[i].desc = descs[name]
+	}
+
+	return &exporterZnodeValue{
+		rules: rules,
+	}
+}
+
+// jsonPathValue 按照a.b.0.c这样的路径从json中取值，数组用下标访问
+func jsonPathValue(data []byte, path string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", err
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, field := range strings.Split(path, ".") {
+			switch current := v.(type) {
+			case map[string]interface{}:
+				var ok bool
+				if v, ok = current[field]; !ok {
+					return "", fmt.Errorf("field '%s' not found", field)
+				}
+			case []interface{}:
+				i, err := strconv.Atoi(field)
+				if err != nil || i < 0 || i >= len(current) {
+					return "", fmt.Errorf("index '%s' out of range", field)
+				}
+				v = current[i]
+			default:
+				return "", fmt.Errorf("field '%s' not found", field)
+			}
+		}
+	}
+
+	switch value := v.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case string:
+		return value, nil
+	}
+	return "", fmt.Errorf("value at '%s' is not a scalar", path)
+}
+
+// extract 从znode的内容中取出数值，true/false分别转换为1/0
+func (x znodeValueExtractor) extract(data []byte) (float64, error) {
+	value := string(data)
+
+	if x.rule.JSONPath != "" {
+		v, err := jsonPathValue(data, x.rule.JSONPath)
+		if err != nil {
+			return 0, err
+		}
+		value = v
+	}
+
+	if x.regex != nil {
+		match := x.regex.FindStringSubmatch(value)
+		if match == nil {
+			return 0, fmt.Errorf("regex '%s' does not match", x.rule.Regex)
+		}
+		value = match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+	}
+
+	value = strings.TrimSpace(value)
+	if b, err := strconv.ParseBool(value); err == nil && !strings.ContainsAny(value, "0123456789") {
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	return parseValue(value)
+}
+
+func (e exporterZnodeValue) Collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	node := ""
+	if n, ok := ctx.Value(nodeName).(string); ok {
+		node = n
+	}
+
+	host := config.ZkHost
+	if h, ok := ctx.Value(zkHost).(string); ok {
+		host = h
+	}
+
+	if len(e.rules) == 0 {
+		return nil
+	}
+
+	session, err := dialSession(host, defaultSessionTimeout)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	for _, rule := range e.rules {
+		data, _, err := session.GetData(rule.rule.Path)
+		if err == errNoNode {
+			log.WithField("path", rule.rule.Path).Debug("znode of value rule does not exist")
+			continue
+		}
+		if _, ok := err.(zkError); !ok && err != nil {
+			// 连接出错时后面的规则也无法读取
+			return err
+		}
+		if err != nil {
+			// 例如NoAuth，只影响这一条规则
+			log.WithError(err).WithField("path", rule.rule.Path).Warn("reading znode of value rule failed")
+			continue
+		}
+
+		v, err := rule.extract(data)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"path": rule.rule.Path, "data": string(data)}).Warn("extract znode value failed")
+			continue
+		}
+
+		log.WithFields(log.Fields{"path": rule.rule.Path, "value": v}).Debug("Set znode value metric")
+		if ch != nil {
+			ch <- mustNewConstMetric(rule.desc, prometheus.GaugeValue, v, append([]string{node}, rule.labelValues...)...)
+		}
+	}
+
+	return nil
+}
+
+func (e exporterZnodeValue) Describe(ch chan<- *prometheus.Desc) {
+	described := make(map[*prometheus.Desc]bool)
+	for _, rule := range e.rules {
+		if !described[rule.desc] {
+			described[rule.desc] = true
+			ch <- rule.desc
+		}
+	}
+
+}
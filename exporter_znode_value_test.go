@@ -0,0 +1,90 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestJSONPathValue(t *testing.T) {
+	data := []byte(`{"count": 42, "flag": true, "name": "demo", "nested": {"items": [{"v": 1.5}, {"v": 2}]}, "obj": {}}`)
+
+	for _, tc := range []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "count", want: "42"},
+		{path: "$.count", want: "42"},
+		{path: "flag", want: "true"},
+		{path: "name", want: "demo"},
+		{path: "nested.items.0.v", want: "1.5"},
+		{path: "$.nested.items.1.v", want: "2"},
+		{path: "missing", wantErr: true},
+		{path: "nested.items.2.v", wantErr: true},
+		{path: "nested.items.x", wantErr: true},
+		{path: "count.x", wantErr: true},
+		{path: "obj", wantErr: true},
+	} {
+		got, err := jsonPathValue(data, tc.path)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("jsonPathValue(%q) = %q, %v, want %q, error %v", tc.path, got, err, tc.want, tc.wantErr)
+		}
+	}
+
+	if _, err := jsonPathValue([]byte("not json"), "count"); err == nil {
+		t.Error("expected an error for invalid json")
+	}
+}
+
+func TestZnodeValueExtract(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rule    znodeValueRule
+		data    string
+		want    float64
+		wantErr bool
+	}{
+		{name: "plain", data: " 42\n", want: 42},
+		{name: "hex", data: "0x10", want: 16},
+		{name: "bool", data: "true", want: 1},
+		{name: "false", data: "false", want: 0},
+		{name: "numeric bool is a number", data: "1", want: 1},
+		{name: "json", rule: znodeValueRule{JSONPath: "count"}, data: `{"count": 7}`, want: 7},
+		{name: "json bool", rule: znodeValueRule{JSONPath: "$.flag"}, data: `{"flag": false}`, want: 0},
+		{name: "regex group", rule: znodeValueRule{Regex: `lag=(\d+)`}, data: "state=ok lag=12", want: 12},
+		{name: "regex match", rule: znodeValueRule{Regex: `\d{3}`}, data: "12345", want: 123},
+		{name: "json and regex", rule: znodeValueRule{JSONPath: "s", Regex: `(\d+)ms`}, data: `{"s": "took 30ms"}`, want: 30},
+		{name: "regex mismatch", rule: znodeValueRule{Regex: `lag=(\d+)`}, data: "state=ok", wantErr: true},
+		{name: "not a number", data: "hello", wantErr: true},
+		{name: "json missing", rule: znodeValueRule{JSONPath: "count"}, data: `{}`, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x := znodeValueExtractor{rule: tc.rule}
+			if tc.rule.Regex != "" {
+				x.regex = regexp.MustCompile(tc.rule.Regex)
+			}
+			got, err := x.extract([]byte(tc.data))
+			if (err != nil) != tc.wantErr || (!tc.wantErr && got != tc.want) {
+				t.Errorf("extract(%q) = %v, %v, want %v, error %v", tc.data, got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestZnodeValueNamesValid(t *testing.T) {
+	for _, tc := range []struct {
+		rule  znodeValueRule
+		valid bool
+	}{
+		{znodeValueRule{Name: "app_count", Labels: map[string]string{"app": "demo"}}, true},
+		{znodeValueRule{Name: "app-count"}, true},
+		{znodeValueRule{Name: ""}, false},
+		{znodeValueRule{Name: "app", Labels: map[string]string{"1app": "demo"}}, false},
+		{znodeValueRule{Name: "app", Labels: map[string]string{"__name__": "x"}}, false},
+		{znodeValueRule{Name: "app", Labels: map[string]string{"node": "x"}}, false},
+	} {
+		if err := znodeValueNamesValid(tc.rule); (err == nil) != tc.valid {
+			t.Errorf("znodeValueNamesValid(%+v) = %v, want valid %v", tc.rule, err, tc.valid)
+		}
+	}
+}
@@ -4,6 +4,8 @@ go 1.14
 
 require (
 	github.com/prometheus/client_golang v1.9.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.15.0
 	github.com/sirupsen/logrus v1.7.0
 	github.com/tkanos/gonfig v0.0.0-20181112185242-896f3d81fadf
 )
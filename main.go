@@ -40,10 +40,11 @@ func main() {
 		panic(err)
 	}
 
+	validateConfig()
 	initLogger()
 	initExtraLabels()
 
-	exporter := newExporter()
+	exporter := newExporter(config.ZkHost, config.ClusterName)
 	prometheus.MustRegister(exporter)
 
 	log.WithFields(log.Fields{
@@ -60,16 +61,23 @@ func main() {
 		"OUTPUT_FORMAT":       config.OutputFormat,
 		"TIMEOUT":      	   config.Timeout,
 		"ExtraLabels":		   config.ExtraLabels,
+		"AUTH":                config.Auth.String(),
+		"TLS_ENABLED":         config.TLS.Enabled,
+		"TRANSPORT":           config.Transport,
 	}).Info("Active Configuration")
 
 	handler := http.NewServeMux()
 	handler.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
+	handler.HandleFunc("/probe", probeHandler)
+	handler.HandleFunc("/acl-audit", aclAuditHandler)
 	handler.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>RabbitMQ Exporter</title></head>
              <body>
              <h1>RabbitMQ Exporter</h1>
              <p><a href='/metrics'>Metrics</a></p>
+             <p><a href='/probe?target=127.0.0.1:2181'>Probe 127.0.0.1:2181</a></p>
+             <p><a href='/acl-audit'>ACL audit</a></p>
              </body>
              </html>`))
 	})
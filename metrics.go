@@ -1,6 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -20,6 +25,82 @@ type StatsInfo struct {
 	metrics MetricMap
 }
 
+// parseValue 将zookeeper返回的值转换为float64，sid/zxid等以0x开头的值按十六进制解析
+func parseValue(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		v, err := strconv.ParseUint(value[2:], 16, 64)
+		if err != nil {
+			return 0, err
+		}
+		return float64(v), nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// noZxid is reported by cons for connections that have not seen any transaction yet
+const noZxid = "0xffffffffffffffff"
+
+// splitZxid 将zxid拆分为epoch(高32位)和counter(低32位)
+func splitZxid(value string) (epoch uint64, counter uint64, err error) {
+	value = strings.TrimSpace(value)
+	if value == noZxid {
+		return 0, 0, fmt.Errorf("no zxid")
+	}
+
+	zxid, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X"), 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return zxid >> 32, zxid & 0xffffffff, nil
+}
+
+//metricUnit is the unit zookeeper reports a value in
+type metricUnit int
+
+const (
+	unitNone metricUnit = iota
+	unitMilliseconds
+	unitBytes
+)
+
+// unitMetricName 按照prometheus的命名规范给指标名加上_seconds/_bytes后缀，
+// config.LegacyMetricNames为true时保持原来不带单位的名字
+func unitMetricName(name string, unit metricUnit) string {
+	if config.LegacyMetricNames {
+		return name
+	}
+
+	switch unit {
+	case unitMilliseconds:
+		return name + "_seconds"
+	case unitBytes:
+		return name + "_bytes"
+	}
+	return name
+}
+
+// unitValue 将毫秒转换为秒，config.LegacyMetricNames为true时保持原始值
+func unitValue(value float64, unit metricUnit) float64 {
+	if config.LegacyMetricNames {
+		return value
+	}
+
+	if unit == unitMilliseconds {
+		return value / 1000
+	}
+	return value
+}
+
+var invalidMetricNameChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// sanitizeMetricName 将不能出现在prometheus指标名中的字符替换为下划线
+func sanitizeMetricName(name string) string {
+	return invalidMetricNameChars.ReplaceAllString(name, "_")
+}
+
 func initExtraLabels() {
 	if config.ExtraLabels != nil {
 		for _, extraLabel := range config.ExtraLabels {
@@ -65,6 +146,24 @@ func newCounterVec(metricName string, docString string, labelNames ...string) *p
 	)
 }
 
+func newHistogramVec(metricName string, docString string, buckets []float64, labelNames ...string) *prometheus.HistogramVec {
+	if labelNames != nil {
+		labelNames = append(labelNames, extraLabelNames...)
+	} else {
+		labelNames = extraLabelNames
+	}
+
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      metricName,
+			Help:      docString,
+			Buckets:   buckets,
+		},
+		labelNames,
+	)
+}
+
 func newDesc(metricName string, docString string, labelNames ...string) *prometheus.Desc {
 	if labelNames != nil {
 		labelNames = append(labelNames, extraLabelNames...)
@@ -102,6 +201,17 @@ func gaugeVecWithLabelValues(v *prometheus.GaugeVec, labelValues ...string) prom
 	return counter
 }
 
+func histogramVecWithLabelValues(v *prometheus.HistogramVec, labelValues ...string) prometheus.Observer {
+	if labelValues != nil {
+		labelValues = append(labelValues, extraLabelValues...)
+	} else {
+		labelValues = extraLabelValues
+	}
+
+	histogram := v.WithLabelValues(labelValues...)
+	return histogram
+}
+
 func mustNewConstHistogram(
 	desc *prometheus.Desc,
 	count uint64,
@@ -121,6 +231,25 @@ func mustNewConstHistogram(
 	return metric
 }
 
+func mustNewConstSummary(
+	desc *prometheus.Desc,
+	count uint64,
+	sum float64,
+	quantiles map[float64]float64,
+	labelValues ...string,
+) prometheus.Metric {
+	if labelValues != nil {
+		labelValues = append(labelValues, extraLabelValues...)
+	} else {
+		labelValues = extraLabelValues
+	}
+
+	metric := prometheus.MustNewConstSummary(
+		desc, count, sum, quantiles, labelValues...
+	)
+	return metric
+}
+
 func mustNewConstMetric(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) prometheus.Metric {
 	if labelValues != nil {
 		labelValues = append(labelValues, extraLabelValues...)
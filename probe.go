@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultZkPort = "2181"
+
+// 缓存的exporter在probeExporterTTL内没有被probe就会被丢弃，缓存最多保留maxProbeExporters个target
+const (
+	probeExporterTTL  = 15 * time.Minute
+	maxProbeExporters = 256
+)
+
+type probeEntry struct {
+	exporter *exporter
+	lastUsed time.Time
+}
+
+var (
+	probeExportersMu sync.Mutex
+	probeExporters   = make(map[string]*probeEntry)
+)
+
+// probeExporter returns the exporter for target. Exporters are cached per target so that
+// state kept by the modules between scrapes survives across probes; targets which are no
+// longer probed are evicted so that arbitrary target parameters cannot grow the cache.
+// cluster is the value of the cluster label, empty to derive it from the membership.
+func probeExporter(target string, cluster string) *exporter {
+	probeExportersMu.Lock()
+	defer probeExportersMu.Unlock()
+
+	now := time.Now()
+	evictProbeExporters(now)
+
+	if entry, ok := probeExporters[target]; ok && entry.exporter.cluster == cluster {
+		entry.lastUsed = now
+		return entry.exporter
+	}
+	delete(probeExporters, target)
+
+	if len(probeExporters) >= maxProbeExporters {
+		// 缓存已满时丢弃最久没有使用的target
+		oldest := ""
+		for t, entry := range probeExporters {
+			if oldest == "" || entry.lastUsed.Before(probeExporters[oldest].lastUsed) {
+				oldest = t
+			}
+		}
+		delete(probeExporters, oldest)
+		evictCapabilities(oldest)
+	}
+
+	e := newExporter(target, cluster)
+	probeExporters[target] = &probeEntry{exporter: e, lastUsed: now}
+	return e
+}
+
+// evictProbeExporters 删除超过probeExporterTTL没有被probe的exporter，调用者需要持有probeExportersMu
+func evictProbeExporters(now time.Time) {
+	for target, entry := range probeExporters {
+		if now.Sub(entry.lastUsed) > probeExporterTTL {
+			log.WithField("target", target).Debug("Evicting idle probe target")
+			delete(probeExporters, target)
+			evictCapabilities(target)
+		}
+	}
+}
+
+// probeHandler 按照blackbox exporter的方式，采集/probe?target=ip:port指定的zookeeper，只返回该target的指标，
+// 可选的cluster参数指定集群级别指标的cluster标签，不指定时根据集群成员生成
+func probeHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+		return
+	}
+
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, defaultZkPort)
+	}
+
+	log.WithField("target", target).Debug("Probing target")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(probeExporter(target, r.URL.Query().Get("cluster")))
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
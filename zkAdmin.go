@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 采集方式，admin使用3.5+的AdminServer(jetty)的/commands接口代替四字命令
+const (
+	transport4lw   = "4lw"
+	transportAdmin = "admin"
+)
+
+// adminCommand 是四字命令对应的AdminServer命令，convert把返回的json转换为四字命令的格式，
+// 这样现有的makeXxxStatsInfo可以不做修改
+type adminCommand struct {
+	name    string
+	convert func(reply map[string]interface{}) []byte
+}
+
+var adminCommands = map[string]adminCommand{
+	"ruok": {"ruok", adminRuok},
+	"mntr": {"monitor", adminMntr},
+	"conf": {"configuration", adminConf},
+	"cons": {"connections", adminCons},
+	"wchs": {"watch_summary", adminWchs},
+	"srvr": {"server_stats", adminSrvr},
+	"stat": {"server_stats", adminSrvr},
+	// voting_view没有对应的四字命令，转换为conf中membership部分的server.N=行
+	"voting_view": {"voting_view", adminVotingView},
+}
+
+// adminTransport 是一个TLS配置共用的http.Transport，证书重新加载后tls.Config会变化，此时替换transport
+type adminTransport struct {
+	tlsConfig *tls.Config
+	transport *http.Transport
+}
+
+var (
+	adminTransportsMu sync.Mutex
+	adminTransports   = make(map[tlsConfig]*adminTransport)
+	adminHTTP         = &http.Transport{}
+)
+
+func adminScheme() string {
+	if config.Admin.Scheme == "" {
+		return "http"
+	}
+	return config.Admin.Scheme
+}
+
+// adminURL 使用host的地址和config.Admin.Port拼出AdminServer的地址
+func adminURL(host string, command string) string {
+	ip, _, err := net.SplitHostPort(host)
+	if err != nil {
+		ip = host
+	}
+	return adminScheme() + "://" + net.JoinHostPort(ip, config.Admin.Port) + "/commands/" + command
+}
+
+// newAdminClient 返回使用共享transport的client，以便复用keep-alive的连接
+func newAdminClient(host string) (*http.Client, error) {
+	client := &http.Client{
+		Timeout:   time.Duration(config.Timeout) * time.Second,
+		Transport: adminHTTP,
+	}
+	if adminScheme() != "https" {
+		return client, nil
+	}
+
+	// https使用与客户端端口相同的证书配置
+	tc := tlsConfigFor(host)
+	c, err := loadTLSConfig(tc)
+	if err != nil {
+		return nil, err
+	}
+
+	adminTransportsMu.Lock()
+	defer adminTransportsMu.Unlock()
+
+	cached, ok := adminTransports[tc]
+	if !ok || cached.tlsConfig != c {
+		if ok {
+			cached.transport.CloseIdleConnections()
+		}
+		transport := &http.Transport{TLSClientConfig: c.Clone()}
+		transport.TLSClientConfig.ServerName = tc.ServerName
+		cached = &adminTransport{tlsConfig: c, transport: transport}
+		adminTransports[tc] = cached
+	}
+	client.Transport = cached.transport
+	return client, nil
+}
+
+// getAdminReply 请求cmd对应的AdminServer命令并转换为四字命令的返回格式
+func getAdminReply(host string, cmd string) ([]byte, error) {
+	command, ok := adminCommands[cmd]
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not supported by the admin transport", cmd)
+	}
+
+	client, err := newAdminClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, adminURL(host, command.name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3.7+的AdminServer认证，格式为 Authorization: digest user:password，密码是明文所以只通过https发送
+	if config.Auth.Mechanism == authDigest {
+		if adminScheme() != "https" {
+			return nil, fmt.Errorf("refusing to send digest credentials to '%s' over plain http, set the admin scheme to https", host)
+		}
+		user, password, err := loadCredentials()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", authDigest+" "+user+":"+password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin command '%s' of '%s' returned %s", command.name, host, resp.Status)
+	}
+
+	reply := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&reply); err != nil {
+		return nil, err
+	}
+	if e, ok := reply["error"]; ok && e != nil {
+		return nil, fmt.Errorf("admin command '%s' of '%s' failed: %v", command.name, host, e)
+	}
+
+	return command.convert(reply), nil
+}
+
+// adminString formats a json value the way the four letter words print it
+func adminString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return fmt.Sprint(v)
+}
+
+// adminHex 将数字格式的sid/zxid转换为四字命令中的0x格式
+func adminHex(v interface{}) string {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return fmt.Sprintf("0x%x", uint64(i))
+		}
+	}
+	return adminString(v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func adminRuok(reply map[string]interface{}) []byte {
+	return []byte("imok")
+}
+
+// monitor的key没有zk_前缀，嵌套的值忽略
+func adminMntr(reply map[string]interface{}) []byte {
+	var b strings.Builder
+	for _, key := range sortedKeys(reply) {
+		switch reply[key].(type) {
+		case string, json.Number, bool:
+			if key != "command" {
+				fmt.Fprintf(&b, "zk_%s\t%s\n", key, adminString(reply[key]))
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// configuration的key是client_port这种格式，conf中是clientPort
+func adminConf(reply map[string]interface{}) []byte {
+	var b strings.Builder
+	for _, key := range sortedKeys(reply) {
+		if key == "command" || key == "error" {
+			continue
+		}
+		parts := strings.Split(key, "_")
+		for i := 1; i < len(parts); i++ {
+			parts[i] = strings.Title(parts[i])
+		}
+		fmt.Fprintf(&b, "%s=%s\n", strings.Join(parts, ""), adminString(reply[key]))
+	}
+	return []byte(b.String())
+}
+
+func adminCons(reply map[string]interface{}) []byte {
+	var b strings.Builder
+	for _, key := range []string{"connections", "secure_connections"} {
+		connections, _ := reply[key].([]interface{})
+		for _, c := range connections {
+			conn, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			addr := strings.TrimPrefix(adminString(conn["remote_socket_address"]), "/")
+			addr = strings.Replace(strings.Replace(addr, "[", "", 1), "]", "", 1)
+
+			fmt.Fprintf(&b, " /%s[%s](queued=%s,recved=%s,sent=%s", addr, adminString(conn["interest_ops"]),
+				adminString(conn["outstanding_requests"]), adminString(conn["packets_received"]), adminString(conn["packets_sent"]))
+			if _, ok := conn["session_id"]; ok {
+				fmt.Fprintf(&b, ",sid=%s,lop=%s,est=%s,to=%s,lcxid=%s,lzxid=%s,lresp=%s,llat=%s,minlat=%s,avglat=%s,maxlat=%s",
+					adminHex(conn["session_id"]), adminString(conn["last_operation"]), adminString(conn["established"]),
+					adminString(conn["session_timeout"]), adminHex(conn["last_cxid"]), adminHex(conn["last_zxid"]),
+					adminString(conn["last_response_time"]), adminString(conn["last_latency"]), adminString(conn["min_latency"]),
+					adminString(conn["avg_latency"]), adminString(conn["max_latency"]))
+			}
+			b.WriteString(")\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+func adminWchs(reply map[string]interface{}) []byte {
+	return []byte(fmt.Sprintf("%s connections watching %s paths\nTotal watches:%s\n",
+		adminString(reply["num_connections"]), adminString(reply["num_paths"]), adminString(reply["num_total_watches"])))
+}
+
+func adminSrvr(reply map[string]interface{}) []byte {
+	stats, _ := reply["server_stats"].(map[string]interface{})
+	if stats == nil {
+		stats = make(map[string]interface{})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Zookeeper version: %s\n", adminString(reply["version"]))
+	fmt.Fprintf(&b, "Latency min/avg/max: %s/%s/%s\n", adminString(stats["min_latency"]), adminString(stats["avg_latency"]), adminString(stats["max_latency"]))
+	fmt.Fprintf(&b, "Received: %s\n", adminString(stats["packets_received"]))
+	fmt.Fprintf(&b, "Sent: %s\n", adminString(stats["packets_sent"]))
+	fmt.Fprintf(&b, "Connections: %s\n", adminString(stats["num_alive_client_connections"]))
+	fmt.Fprintf(&b, "Outstanding: %s\n", adminString(stats["outstanding_requests"]))
+	fmt.Fprintf(&b, "Zxid: %s\n", adminHex(stats["last_processed_zxid"]))
+	fmt.Fprintf(&b, "Mode: %s\n", adminString(stats["server_state"]))
+	fmt.Fprintf(&b, "Node count: %s\n", adminString(reply["node_count"]))
+	return []byte(b.String())
+}
+
+// voting_view的current_config是server id到QuorumServer.toString()的映射，standalone时为空，
+// 例如 {"1": "10.0.0.1:2888:3888:participant;0.0.0.0:2181"}
+func adminVotingView(reply map[string]interface{}) []byte {
+	view, _ := reply["current_config"].(map[string]interface{})
+
+	var b strings.Builder
+	for _, id := range sortedKeys(view) {
+		fmt.Fprintf(&b, "server.%s=%s\n", id, adminString(view[id]))
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func decodeAdminReply(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	reply := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.UseNumber()
+	if err := dec.Decode(&reply); err != nil {
+		t.Fatalf("decoding %q: %v", body, err)
+	}
+	return reply
+}
+
+func TestAdminConverters(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		convert func(reply map[string]interface{}) []byte
+		body    string
+		want    string
+	}{
+		{
+			name:    "monitor",
+			convert: adminMntr,
+			body: `{"version":"3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715, built on 09/04/2020 12:44 GMT","avg_latency":0.0,` +
+				`"max_latency":12,"min_latency":0,"packets_received":120,"server_state":"leader","znode_count":5,` +
+				`"read_latency":{"count":0},"command":"monitor","error":null}`,
+			want: "zk_avg_latency\t0.0\n" +
+				"zk_max_latency\t12\n" +
+				"zk_min_latency\t0\n" +
+				"zk_packets_received\t120\n" +
+				"zk_server_state\tleader\n" +
+				"zk_version\t3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715, built on 09/04/2020 12:44 GMT\n" +
+				"zk_znode_count\t5\n",
+		},
+		{
+			name:    "configuration",
+			convert: adminConf,
+			body: `{"client_port":2181,"data_dir":"/data/version-2","tick_time":2000,"max_client_cnxns":60,` +
+				`"server_id":1,"command":"configuration","error":null}`,
+			want: "clientPort=2181\ndataDir=/data/version-2\nmaxClientCnxns=60\nserverId=1\ntickTime=2000\n",
+		},
+		{
+			name:    "connections",
+			convert: adminCons,
+			body: `{"connections":[{"remote_socket_address":"/127.0.0.1:54321","interest_ops":1,"outstanding_requests":0,` +
+				`"packets_received":10,"packets_sent":9,"session_id":72057594037927937,"last_operation":"PING",` +
+				`"established":1612345678901,"session_timeout":30000,"last_cxid":5,"last_zxid":-1,` +
+				`"last_response_time":1612345679901,"last_latency":1,"min_latency":0,"avg_latency":0.5,"max_latency":3}],` +
+				`"secure_connections":[{"remote_socket_address":"[0:0:0:0:0:0:0:1]:5555","interest_ops":0,` +
+				`"outstanding_requests":0,"packets_received":1,"packets_sent":0}],"command":"connections","error":null}`,
+			want: " /127.0.0.1:54321[1](queued=0,recved=10,sent=9,sid=0x100000000000001,lop=PING,est=1612345678901," +
+				"to=30000,lcxid=0x5,lzxid=0xffffffffffffffff,lresp=1612345679901,llat=1,minlat=0,avglat=0.5,maxlat=3)\n" +
+				" /0:0:0:0:0:0:0:1:5555[0](queued=0,recved=1,sent=0)\n",
+		},
+		{
+			name:    "server_stats",
+			convert: adminSrvr,
+			body: `{"version":"3.6.2--803c7f1a, built on 09/04/2020 12:44 GMT","read_only":false,"server_stats":{` +
+				`"packets_sent":119,"packets_received":120,"max_latency":12,"min_latency":0,"avg_latency":0.5,` +
+				`"outstanding_requests":0,"last_processed_zxid":8589934597,"num_alive_client_connections":2,` +
+				`"server_state":"leader"},"node_count":5,"command":"stats","error":null}`,
+			want: "Zookeeper version: 3.6.2--803c7f1a, built on 09/04/2020 12:44 GMT\n" +
+				"Latency min/avg/max: 0/0.5/12\n" +
+				"Received: 120\n" +
+				"Sent: 119\n" +
+				"Connections: 2\n" +
+				"Outstanding: 0\n" +
+				"Zxid: 0x200000005\n" +
+				"Mode: leader\n" +
+				"Node count: 5\n",
+		},
+		{
+			name:    "voting_view",
+			convert: adminVotingView,
+			body: `{"current_config":{"2":"10.0.0.2:2888:3888:participant;0.0.0.0:2181",` +
+				`"1":"10.0.0.1:2888:3888:participant;0.0.0.0:2181"},"command":"voting_view","error":null}`,
+			want: "server.1=10.0.0.1:2888:3888:participant;0.0.0.0:2181\n" +
+				"server.2=10.0.0.2:2888:3888:participant;0.0.0.0:2181\n",
+		},
+		{
+			name:    "standalone voting_view",
+			convert: adminVotingView,
+			body:    `{"current_config":{},"command":"voting_view","error":null}`,
+			want:    "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(tc.convert(decodeAdminReply(t, tc.body))); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
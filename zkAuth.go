@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 认证方式
+const (
+	authDigest = "digest"
+	authSASL   = "sasl"
+)
+
+// DIGEST-MD5的digest-uri，与java客户端的zookeeper/zk-sasl-md5保持一致
+const (
+	saslProtocol    = "zookeeper"
+	saslServerName  = "zk-sasl-md5"
+	saslDefaultQop  = "auth"
+	saslNonceCount  = "00000001"
+	saslMaxExchange = 4
+)
+
+var errNoCredentials = errors.New("zk: auth is configured but no credentials were found")
+
+// loadCredentials 每次建立session时重新读取，这样凭证更新后不需要重启exporter
+func loadCredentials() (string, string, error) {
+	var credentials string
+	switch {
+	case config.Auth.CredentialsFile != "":
+		b, err := ioutil.ReadFile(config.Auth.CredentialsFile)
+		if err != nil {
+			return "", "", err
+		}
+		credentials = string(b)
+	case config.Auth.CredentialsEnv != "":
+		credentials = os.Getenv(config.Auth.CredentialsEnv)
+	}
+
+	user := strings.SplitN(strings.TrimSpace(credentials), ":", 2)
+	if len(user) != 2 || user[0] == "" {
+		return "", "", errNoCredentials
+	}
+	return user[0], user[1], nil
+}
+
+// authenticate 按照config.Auth对session进行认证，没有配置时什么都不做
+func (s *zkSession) authenticate() error {
+	if config.Auth.Mechanism == "" {
+		return nil
+	}
+
+	user, password, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+
+	switch config.Auth.Mechanism {
+	case authDigest:
+		return s.AddAuth(authDigest, []byte(user+":"+password))
+	case authSASL:
+		return s.saslDigestMD5(user, password)
+	}
+	return fmt.Errorf("zk: unknown auth mechanism %q", config.Auth.Mechanism)
+}
+
+// AddAuth sends an AuthPacket, failing with errAuthFailed for wrong credentials
+func (s *zkSession) AddAuth(scheme string, auth []byte) error {
+	enc := &juteEncoder{}
+	enc.writeInt(0)
+	enc.writeString(scheme)
+	enc.writeBuffer(auth)
+	_, err := s.call(xidAuth, opAuth, enc.bytes())
+	return err
+}
+
+func (s *zkSession) sasl(token []byte) ([]byte, error) {
+	enc := &juteEncoder{}
+	enc.writeBuffer(token)
+	resp, err := s.request(opSASL, enc.bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := resp.body.readBuffer()
+	return challenge, resp.body.err
+}
+
+// saslDigestMD5 实现RFC 2831中客户端的部分，zookeeper的DIGEST-MD5没有initial response，
+// 第一个请求是空的token
+func (s *zkSession) saslDigestMD5(user string, password string) error {
+	challenge, err := s.sasl([]byte{})
+	if err != nil {
+		return err
+	}
+
+	var rspauth string
+	for i := 0; i < saslMaxExchange; i++ {
+		directives := parseDigestChallenge(string(challenge))
+		if auth, ok := directives["rspauth"]; ok {
+			if auth != rspauth {
+				return errAuthFailed
+			}
+			return nil
+		}
+
+		var response string
+		response, rspauth, err = digestMD5Response(directives, user, password)
+		if err != nil {
+			return err
+		}
+		if challenge, err = s.sasl([]byte(response)); err != nil {
+			return err
+		}
+		if len(challenge) == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("zk: sasl exchange with %s did not complete", s.host)
+}
+
+// parseDigestChallenge 解析 realm="zk-sasl-md5",nonce="...",qop="auth",charset=utf-8,algorithm=md5-sess
+func parseDigestChallenge(challenge string) map[string]string {
+	directives := make(map[string]string)
+	for len(challenge) > 0 {
+		kv := strings.SplitN(challenge, "=", 2)
+		if len(kv) != 2 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		rest := strings.TrimSpace(kv[1])
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+
+		directives[key] = value
+		challenge = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return directives
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// digestMD5Response returns the response to the challenge and the rspauth expected from the server
+func digestMD5Response(directives map[string]string, user string, password string) (string, string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return digestMD5ResponseWith(directives, user, password, hex.EncodeToString(b), saslProtocol+"/"+saslServerName)
+}
+
+// digestMD5ResponseWith 按照RFC 2831计算response，cnonce和digest-uri由调用者指定
+func digestMD5ResponseWith(directives map[string]string, user string, password string, cnonce string, digestURI string) (string, string, error) {
+	nonce := directives["nonce"]
+	if nonce == "" {
+		return "", "", fmt.Errorf("zk: sasl challenge without nonce")
+	}
+	realm, ok := directives["realm"]
+	if !ok {
+		realm = saslServerName
+	}
+	qop := saslDefaultQop
+	if q, ok := directives["qop"]; ok {
+		// qop是逗号分隔的列表，例如auth,auth-int
+		supported := false
+		for _, option := range strings.Split(q, ",") {
+			if strings.TrimSpace(option) == saslDefaultQop {
+				supported = true
+			}
+		}
+		if !supported {
+			return "", "", fmt.Errorf("zk: unsupported sasl qop %q", q)
+		}
+	}
+
+	hash := md5.Sum([]byte(user + ":" + realm + ":" + password))
+	ha1 := md5Hex(string(hash[:]) + ":" + nonce + ":" + cnonce)
+	kd := func(a2 string) string {
+		return md5Hex(ha1 + ":" + nonce + ":" + saslNonceCount + ":" + cnonce + ":" + qop + ":" + md5Hex(a2))
+	}
+
+	response := fmt.Sprintf(`charset=utf-8,username="%s",realm="%s",nonce="%s",nc=%s,cnonce="%s",digest-uri="%s",maxbuf=65536,response=%s,qop=%s`,
+		user, realm, nonce, saslNonceCount, cnonce, digestURI, kd("AUTHENTICATE:"+digestURI), qop)
+	return response, kd(":" + digestURI), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// RFC 2831 4. Example
+func TestDigestMD5Response(t *testing.T) {
+	directives := parseDigestChallenge(`realm="elwood.innosoft.com",nonce="OA6MG9tEQGm2hh",qop="auth",algorithm=md5-sess,charset=utf-8`)
+
+	response, rspauth, err := digestMD5ResponseWith(directives, "chris", "secret", "OA6MHXh6VqTrRk", "imap/elwood.innosoft.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`username="chris"`,
+		`realm="elwood.innosoft.com"`,
+		`nonce="OA6MG9tEQGm2hh"`,
+		`nc=00000001`,
+		`cnonce="OA6MHXh6VqTrRk"`,
+		`digest-uri="imap/elwood.innosoft.com"`,
+		`response=d388dad90d4bbd760a152321f2143af7`,
+		`qop=auth`,
+	} {
+		if !strings.Contains(response, want) {
+			t.Errorf("response %q does not contain %q", response, want)
+		}
+	}
+	if rspauth != "ea40f60335c427b5527b84dbabcdfffd" {
+		t.Errorf("rspauth = %s, want ea40f60335c427b5527b84dbabcdfffd", rspauth)
+	}
+}
+
+func TestDigestMD5ResponseErrors(t *testing.T) {
+	if _, _, err := digestMD5Response(map[string]string{}, "user", "password"); err == nil {
+		t.Error("expected an error for a challenge without nonce")
+	}
+	if _, _, err := digestMD5Response(map[string]string{"nonce": "n", "qop": "auth-conf"}, "user", "password"); err == nil {
+		t.Error("expected an error for an unsupported qop")
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	got := parseDigestChallenge(`realm="zk-sasl-md5",nonce="a,b",qop="auth",charset=utf-8,algorithm=md5-sess`)
+	for key, want := range map[string]string{
+		"realm":     "zk-sasl-md5",
+		"nonce":     "a,b",
+		"qop":       "auth",
+		"charset":   "utf-8",
+		"algorithm": "md5-sess",
+	} {
+		if got[key] != want {
+			t.Errorf("%s = %q, want %q", key, got[key], want)
+		}
+	}
+}
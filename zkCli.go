@@ -1,39 +1,63 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"strings"
 	"time"
 )
 
-func newClient() (net.Conn, error) {
+// zookeeper在命令不在4lw.commands.whitelist中时返回的提示
+const notWhitelistedReply = "is not executed because it is not in the whitelist"
+
+func isNotWhitelisted(reply []byte) bool {
+	return strings.Contains(string(reply), notWhitelistedReply)
+}
+
+//notWhitelistedError is returned when zookeeper refuses a four letter word by its whitelist
+type notWhitelistedError struct {
+	cmd  string
+	host string
+}
+
+func (e *notWhitelistedError) Error() string {
+	return fmt.Sprintf("'%s' is not in the 4lw.commands.whitelist of '%s'", e.cmd, e.host)
+}
+
+func newClient(host string) (net.Conn, error) {
 	timeout := time.Duration(config.Timeout) * time.Second
 	dialer := net.Dialer{Timeout: timeout}
-	zkAddr, err := net.ResolveTCPAddr("tcp", config.ZkHost)
+	zkAddr, err := net.ResolveTCPAddr("tcp", host)
 	if err != nil {
-		log.Printf("warning: cannot resolve zk hostname '%s': %s", config.ZkHost, err)
+		log.Printf("warning: cannot resolve zk hostname '%s': %s", host, err)
 		return nil, err
 	}
 
-	conn, err := dialer.Dial("tcp", zkAddr.String())
+	var conn net.Conn
+	if tc := tlsConfigFor(host); tc.Enabled {
+		conn, err = dialTLS(&dialer, host, zkAddr.String(), tc)
+	} else {
+		conn, err = dialer.Dial("tcp", zkAddr.String())
+	}
 	if err != nil {
-		log.Printf("warning: cannot connect to %s: %v", config.ZkHost, err)
+		log.Printf("warning: cannot connect to %s: %v", host, err)
 		return nil, err
 	}
 
 	return conn, nil
 }
 
-func getStatsInfo(makeStatsInfo func(body []byte, labels ...string) []StatsInfo, apiEndpoint string, labels ...string) ([]StatsInfo, error) {
+func getStatsInfo(host string, makeStatsInfo func(body []byte, labels ...string) []StatsInfo, apiEndpoint string, labels ...string) ([]StatsInfo, error) {
 	var q []StatsInfo
 
-	client, err := newClient()
-	defer client.Close()
-	if err != nil {
-		return nil, err
+	getReply := getFourLetterReply
+	if config.Transport == transportAdmin {
+		getReply = getAdminReply
 	}
-	reply, err := sendZookeeperCmd(client, config.ZkHost, apiEndpoint)
+
+	reply, err := getReply(host, apiEndpoint)
 	if err != nil {
 		return q, err
 	}
@@ -43,7 +67,29 @@ func getStatsInfo(makeStatsInfo func(body []byte, labels ...string) []StatsInfo,
 	return q, nil
 }
 
+func getFourLetterReply(host string, cmd string) ([]byte, error) {
+	client, err := newClient(host)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	reply, err := sendZookeeperCmd(client, host, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if isNotWhitelisted(reply) {
+		return nil, &notWhitelistedError{cmd: cmd, host: host}
+	}
+
+	return reply, nil
+}
+
 func sendZookeeperCmd(conn net.Conn, host, cmd string) ([]byte, error) {
+	// 服务端回复后会关闭连接，TLS连接上对端可能不发送close_notify，需要超时保护
+	conn.SetDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
+
 	_, err := conn.Write([]byte(cmd))
 	if err != nil {
 		log.Printf("warning: failed to send '%s' to '%s': %s", cmd, host, err)
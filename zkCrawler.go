@@ -0,0 +1,276 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	crawlersMu sync.Mutex
+	crawlers   = make(map[string]*zkCrawler)
+)
+
+// crawlerIdleIntervals 连续这么多个周期没有被读取的crawler会停止，例如不再被probe的target
+const crawlerIdleIntervals = 3
+
+// zkCrawler 在后台按照config.Crawler.Interval周期性地遍历一个zookeeper的znode树，
+// Collect只读取最近一次遍历的结果
+type zkCrawler struct {
+	host     string
+	mutex    sync.RWMutex
+	result   *crawlResult
+	err      error
+	lastRead time.Time // 由crawlersMu保护
+}
+
+// crawlResult is the outcome of one walk over the configured roots
+type crawlResult struct {
+	prefixes  map[string]*subtreeStats
+	visited   int
+	denied    int
+	truncated bool
+	offenders []aclOffender
+	unknown   []string // ACL不可读的节点
+	duration  time.Duration
+	finished  time.Time
+	err       error
+}
+
+// subtreeStats aggregates the znodes below one configured prefix
+type subtreeStats struct {
+	nodes         float64
+	children      float64
+	bytes         float64
+	acls          map[aclKey]float64
+	worldWritable float64
+	aclUnknown    float64
+}
+
+// aclKey identifies the nodes granting perm to scheme
+type aclKey struct {
+	scheme string
+	perm   string
+}
+
+// aclOffender is a znode writable by world:anyone
+type aclOffender struct {
+	path string
+	acl  string
+}
+
+func (s *subtreeStats) addACLs(acls []zkACL) {
+	seen := make(map[aclKey]bool)
+	for _, acl := range acls {
+		for _, p := range permNames {
+			key := aclKey{scheme: acl.Scheme, perm: p.name}
+			if acl.Perms&p.perm != 0 && !seen[key] {
+				seen[key] = true
+				s.acls[key]++
+			}
+		}
+	}
+}
+
+// getCrawler returns the crawler of host, starting it on first use.
+func getCrawler(host string) *zkCrawler {
+	crawlersMu.Lock()
+	defer crawlersMu.Unlock()
+
+	if c, ok := crawlers[host]; ok {
+		c.lastRead = time.Now()
+		return c
+	}
+
+	c := &zkCrawler{host: host, lastRead: time.Now()}
+	crawlers[host] = c
+	go c.run()
+	return c
+}
+
+// lookupCrawler returns the running crawler of host, nil if there is none.
+// Unlike getCrawler it neither starts a crawler nor counts as a read.
+func lookupCrawler(host string) *zkCrawler {
+	crawlersMu.Lock()
+	defer crawlersMu.Unlock()
+	return crawlers[host]
+}
+
+// stopIfIdle 在crawler连续crawlerIdleIntervals个周期没有被读取时将其移除，返回true表示应该停止
+func (c *zkCrawler) stopIfIdle(interval time.Duration) bool {
+	crawlersMu.Lock()
+	defer crawlersMu.Unlock()
+
+	if time.Since(c.lastRead) < crawlerIdleIntervals*interval {
+		return false
+	}
+	if crawlers[c.host] == c {
+		delete(crawlers, c.host)
+	}
+	return true
+}
+
+func (c *zkCrawler) run() {
+	interval := time.Duration(config.Crawler.Interval) * time.Second
+	for {
+		if c.stopIfIdle(interval) {
+			log.WithField("host", c.host).Info("stopping idle znode crawler")
+			return
+		}
+
+		result := c.crawl()
+
+		c.mutex.Lock()
+		if result.err != nil {
+			log.WithError(result.err).WithField("host", c.host).Warn("crawling znode tree failed")
+			c.err = result.err
+		} else {
+			c.result = result
+			c.err = nil
+		}
+		c.mutex.Unlock()
+
+		time.Sleep(interval)
+	}
+}
+
+// Result returns the last successful crawl, nil if none finished yet,
+// and the error of the last attempt.
+func (c *zkCrawler) Result() (*crawlResult, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.result, c.err
+}
+
+func crawlPrefixes() []string {
+	if len(config.Crawler.Prefixes) > 0 {
+		return config.Crawler.Prefixes
+	}
+	return config.Crawler.Roots
+}
+
+// underPrefix 判断path是否在prefix的子树中(包括prefix本身)
+func underPrefix(path string, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// ownNode 判断path是否是exporter自己在canary_path下创建的节点，没有配置auth时这些节点只能是world:anyone，不计入审计
+func ownNode(path string) bool {
+	return config.CanaryPath != "/" && underPrefix(path, config.CanaryPath)
+}
+
+func childPath(parent string, child string) string {
+	if parent == "/" {
+		return "/" + child
+	}
+	return parent + "/" + child
+}
+
+// crawl 从每个root开始广度优先遍历，深度超过MaxDepth的节点不再展开，访问的节点数达到MaxNodes时停止
+func (c *zkCrawler) crawl() *crawlResult {
+	start := time.Now()
+	result := &crawlResult{prefixes: make(map[string]*subtreeStats)}
+	for _, prefix := range crawlPrefixes() {
+		result.prefixes[prefix] = &subtreeStats{acls: make(map[aclKey]float64)}
+	}
+
+	session, err := dialSession(c.host, defaultSessionTimeout)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer session.Close()
+
+	type item struct {
+		path  string
+		depth int
+	}
+
+	visited := make(map[string]bool)
+	for _, root := range config.Crawler.Roots {
+		queue := []item{{path: root}}
+		for len(queue) > 0 && !result.truncated {
+			current := queue[0]
+			queue = queue[1:]
+			if visited[current.path] {
+				continue
+			}
+
+			if result.visited >= config.Crawler.MaxNodes {
+				result.truncated = true
+				break
+			}
+
+			children, stat, err := session.GetChildren(current.path)
+			if err == errNoAuth {
+				// 没有READ权限的节点无法列出子节点，但exists不检查权限
+				result.denied++
+				stat, err = session.Stat(current.path)
+			}
+			if err == errNoNode {
+				// 遍历过程中被删除的节点
+				continue
+			}
+			if err != nil {
+				result.err = err
+				return result
+			}
+
+			visited[current.path] = true
+			result.visited++
+
+			var acls []zkACL
+			aclUnknown, worldWritable := false, false
+			if aclAuditEnabled() {
+				acls, _, err = session.GetACL(current.path)
+				if err != nil && err != errNoAuth && err != errNoNode {
+					result.err = err
+					return result
+				}
+				// getACL需要READ或ADMIN权限，读不到ACL的节点不能当作安全的
+				if err == errNoAuth {
+					aclUnknown = true
+					result.unknown = append(result.unknown, current.path)
+				}
+				if offending := worldWritablePerms(acls); offending != 0 && !ownNode(current.path) {
+					worldWritable = true
+					result.offenders = append(result.offenders, aclOffender{path: current.path, acl: "world:anyone:" + permString(offending)})
+				}
+			}
+
+			for prefix, stats := range result.prefixes {
+				if underPrefix(current.path, prefix) {
+					stats.nodes++
+					stats.children += float64(stat.NumChildren)
+					stats.bytes += float64(stat.DataLength)
+					stats.addACLs(acls)
+					if aclUnknown {
+						stats.aclUnknown++
+					}
+					if worldWritable {
+						stats.worldWritable++
+					}
+				}
+			}
+
+			if current.depth >= config.Crawler.MaxDepth {
+				continue
+			}
+			for _, child := range children {
+				queue = append(queue, item{path: childPath(current.path, child), depth: current.depth + 1})
+			}
+		}
+	}
+
+	result.duration = time.Since(start)
+	result.finished = time.Now()
+
+	log.WithFields(log.Fields{"host": c.host, "visited": result.visited, "truncated": result.truncated, "duration": result.duration}).Debug("crawled znode tree")
+
+	return result
+}
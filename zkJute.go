@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// zookeeper客户端协议使用jute序列化，所有整数都是大端序，buffer/string/vector以int长度开头(-1表示null)
+
+var errJuteShortBuffer = errors.New("jute: short buffer")
+
+type juteEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *juteEncoder) writeInt(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	e.buf.Write(b[:])
+}
+
+func (e *juteEncoder) writeLong(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf.Write(b[:])
+}
+
+func (e *juteEncoder) writeBool(v bool) {
+	if v {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *juteEncoder) writeBuffer(v []byte) {
+	if v == nil {
+		e.writeInt(-1)
+		return
+	}
+	e.writeInt(int32(len(v)))
+	e.buf.Write(v)
+}
+
+func (e *juteEncoder) writeString(v string) {
+	e.writeInt(int32(len(v)))
+	e.buf.WriteString(v)
+}
+
+func (e *juteEncoder) bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// juteDecoder keeps the first error and returns zero values afterwards,
+// so a record can be read field by field and checked once at the end.
+type juteDecoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func newJuteDecoder(buf []byte) *juteDecoder {
+	return &juteDecoder{buf: buf}
+}
+
+func (d *juteDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > len(d.buf) {
+		d.err = errJuteShortBuffer
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *juteDecoder) readInt() int32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *juteDecoder) readLong() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *juteDecoder) readBuffer() []byte {
+	n := d.readInt()
+	if n < 0 {
+		return nil
+	}
+	b := d.next(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func (d *juteDecoder) readString() string {
+	return string(d.readBuffer())
+}
+
+func (d *juteDecoder) readStrings() []string {
+	n := d.readInt()
+	if n < 0 {
+		return nil
+	}
+	var v []string
+	for i := int32(0); i < n && d.err == nil; i++ {
+		v = append(v, d.readString())
+	}
+	return v
+}
+
+// zkStat is the jute Stat record of a znode
+type zkStat struct {
+	Czxid          int64
+	Mzxid          int64
+	Ctime          int64
+	Mtime          int64
+	Version        int32
+	Cversion       int32
+	Aversion       int32
+	EphemeralOwner int64
+	DataLength     int32
+	NumChildren    int32
+	Pzxid          int64
+}
+
+func (d *juteDecoder) readStat() *zkStat {
+	return &zkStat{
+		Czxid:          d.readLong(),
+		Mzxid:          d.readLong(),
+		Ctime:          d.readLong(),
+		Mtime:          d.readLong(),
+		Version:        d.readInt(),
+		Cversion:       d.readInt(),
+		Aversion:       d.readInt(),
+		EphemeralOwner: d.readLong(),
+		DataLength:     d.readInt(),
+		NumChildren:    d.readInt(),
+		Pzxid:          d.readLong(),
+	}
+}
+
+// zkACL is one entry of the ACL of a znode
+type zkACL struct {
+	Perms  int32
+	Scheme string
+	ID     string
+}
+
+// ACL中的权限位
+const (
+	permRead   int32 = 1 << 0
+	permWrite  int32 = 1 << 1
+	permCreate int32 = 1 << 2
+	permDelete int32 = 1 << 3
+	permAdmin  int32 = 1 << 4
+)
+
+var permNames = []struct {
+	perm int32
+	name string
+}{
+	{permRead, "read"},
+	{permWrite, "write"},
+	{permCreate, "create"},
+	{permDelete, "delete"},
+	{permAdmin, "admin"},
+}
+
+// permString 返回zkCli风格的权限字符串，例如cdrwa
+func permString(perms int32) string {
+	s := ""
+	for i, perm := range []int32{permCreate, permDelete, permRead, permWrite, permAdmin} {
+		if perms&perm != 0 {
+			s += string("cdrwa"[i])
+		}
+	}
+	return s
+}
+
+func (d *juteDecoder) readACLs() []zkACL {
+	n := d.readInt()
+	if n < 0 {
+		return nil
+	}
+	var acls []zkACL
+	for i := int32(0); i < n && d.err == nil; i++ {
+		acls = append(acls, zkACL{Perms: d.readInt(), Scheme: d.readString(), ID: d.readString()})
+	}
+	return acls
+}
+
+func (e *juteEncoder) writeACLs(acls []zkACL) {
+	e.writeInt(int32(len(acls)))
+	for _, acl := range acls {
+		e.writeInt(acl.Perms)
+		e.writeString(acl.Scheme)
+		e.writeString(acl.ID)
+	}
+}
+
+// worldACL is the OPEN_ACL_UNSAFE of the java client
+var worldACL = []zkACL{{Perms: permRead | permWrite | permCreate | permDelete | permAdmin, Scheme: "world", ID: "anyone"}}
+
+// creatorACL is the CREATOR_ALL_ACL of the java client, only the authenticated identity of the creator has access
+var creatorACL = []zkACL{{Perms: permRead | permWrite | permCreate | permDelete | permAdmin, Scheme: "auth", ID: ""}}
+
+// create的flags
+const (
+	flagPersistent int32 = 0
+	flagEphemeral  int32 = 1
+)
+
+// zookeeper的操作码
+const (
+	opCreate       int32 = 1
+	opDelete       int32 = 2
+	opExists       int32 = 3
+	opGetData      int32 = 4
+	opSetData      int32 = 5
+	opGetACL       int32 = 6
+	opSync         int32 = 9
+	opGetChildren2 int32 = 12
+	opPing         int32 = 11
+	opCloseSession int32 = -11
+	opAuth         int32 = 100
+	opSASL         int32 = 102
+)
+
+// 特殊的xid
+const (
+	xidNotification int32 = -1
+	xidPing         int32 = -2
+	xidAuth         int32 = -4
+)
+
+// WatcherEvent的类型
+const (
+	eventNodeCreated         int32 = 1
+	eventNodeDeleted         int32 = 2
+	eventNodeDataChanged     int32 = 3
+	eventNodeChildrenChanged int32 = 4
+)
+
+// zkError is an error code returned by zookeeper in the reply header
+type zkError int32
+
+const (
+	errSystemError      zkError = -1
+	errConnectionLoss   zkError = -4
+	errOperationTimeout zkError = -7
+	errAPIError         zkError = -100
+	errNoNode           zkError = -101
+	errNoAuth           zkError = -102
+	errBadVersion       zkError = -103
+	errNodeExists       zkError = -110
+	errNotEmpty         zkError = -111
+	errSessionExpired   zkError = -112
+	errInvalidACL       zkError = -114
+	errAuthFailed       zkError = -115
+	errNotReadOnly      zkError = -119
+)
+
+var zkErrorNames = map[zkError]string{
+	errSystemError:      "system error",
+	errConnectionLoss:   "connection loss",
+	errOperationTimeout: "operation timeout",
+	errAPIError:         "api error",
+	errNoNode:           "node does not exist",
+	errNoAuth:           "not authenticated",
+	errBadVersion:       "version conflict",
+	errNodeExists:       "node already exists",
+	errNotEmpty:         "node has children",
+	errSessionExpired:   "session has been expired by the server",
+	errInvalidACL:       "invalid ACL specified",
+	errAuthFailed:       "client authentication failed",
+	errNotReadOnly:      "server is read-only",
+}
+
+func (e zkError) Error() string {
+	if name, ok := zkErrorNames[e]; ok {
+		return "zk: " + name
+	}
+	return fmt.Sprintf("zk: unknown error %d", int32(e))
+}
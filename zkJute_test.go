@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestJuteEncoding(t *testing.T) {
+	e := &juteEncoder{}
+	e.writeInt(2)
+	e.writeLong(-1)
+	e.writeBool(true)
+	e.writeBuffer(nil)
+	e.writeString("ab")
+
+	want := []byte{
+		0, 0, 0, 2,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		1,
+		0xff, 0xff, 0xff, 0xff,
+		0, 0, 0, 2, 'a', 'b',
+	}
+	if !bytes.Equal(e.bytes(), want) {
+		t.Errorf("encoded % x, want % x", e.bytes(), want)
+	}
+}
+
+func TestJuteRoundTrip(t *testing.T) {
+	acls := []zkACL{
+		{Perms: permRead | permWrite, Scheme: "digest", ID: "user:hash"},
+		{Perms: permRead, Scheme: "world", ID: "anyone"},
+	}
+
+	e := &juteEncoder{}
+	e.writeInt(-42)
+	e.writeLong(1 << 40)
+	e.writeBuffer([]byte("data"))
+	e.writeBuffer(nil)
+	e.writeString("/path")
+	e.writeInt(2)
+	e.writeString("a")
+	e.writeString("b")
+	e.writeACLs(acls)
+
+	d := newJuteDecoder(e.bytes())
+	if v := d.readInt(); v != -42 {
+		t.Errorf("readInt() = %d, want -42", v)
+	}
+	if v := d.readLong(); v != 1<<40 {
+		t.Errorf("readLong() = %d, want %d", v, int64(1<<40))
+	}
+	if v := d.readBuffer(); string(v) != "data" {
+		t.Errorf("readBuffer() = %q, want \"data\"", v)
+	}
+	if v := d.readBuffer(); v != nil {
+		t.Errorf("readBuffer() = %q, want nil", v)
+	}
+	if v := d.readString(); v != "/path" {
+		t.Errorf("readString() = %q, want \"/path\"", v)
+	}
+	if v := d.readStrings(); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("readStrings() = %q, want [a b]", v)
+	}
+	if v := d.readACLs(); !reflect.DeepEqual(v, acls) {
+		t.Errorf("readACLs() = %+v, want %+v", v, acls)
+	}
+	if d.err != nil {
+		t.Errorf("unexpected error %v", d.err)
+	}
+}
+
+func TestJuteReadStat(t *testing.T) {
+	want := &zkStat{
+		Czxid:          0x100000001,
+		Mzxid:          0x100000002,
+		Ctime:          1612345678901,
+		Mtime:          1612345678902,
+		Version:        3,
+		Cversion:       4,
+		Aversion:       5,
+		EphemeralOwner: 0x10000a4b1e20001,
+		DataLength:     6,
+		NumChildren:    7,
+		Pzxid:          0x100000003,
+	}
+
+	e := &juteEncoder{}
+	e.writeLong(want.Czxid)
+	e.writeLong(want.Mzxid)
+	e.writeLong(want.Ctime)
+	e.writeLong(want.Mtime)
+	e.writeInt(want.Version)
+	e.writeInt(want.Cversion)
+	e.writeInt(want.Aversion)
+	e.writeLong(want.EphemeralOwner)
+	e.writeInt(want.DataLength)
+	e.writeInt(want.NumChildren)
+	e.writeLong(want.Pzxid)
+
+	d := newJuteDecoder(e.bytes())
+	if got := d.readStat(); !reflect.DeepEqual(got, want) || d.err != nil {
+		t.Errorf("readStat() = %+v, %v, want %+v", got, d.err, want)
+	}
+	if d.off != len(e.bytes()) {
+		t.Errorf("readStat() consumed %d bytes, want %d", d.off, len(e.bytes()))
+	}
+}
+
+func TestJuteShortBuffer(t *testing.T) {
+	d := newJuteDecoder([]byte{0, 0, 0, 5, 'a', 'b'})
+	if v := d.readString(); v != "" {
+		t.Errorf("readString() = %q, want \"\"", v)
+	}
+	if d.err != errJuteShortBuffer {
+		t.Errorf("err = %v, want %v", d.err, errJuteShortBuffer)
+	}
+	// 出错之后的读取都返回零值
+	if v := d.readInt(); v != 0 {
+		t.Errorf("readInt() after error = %d, want 0", v)
+	}
+}
+
+func TestPermString(t *testing.T) {
+	for _, tc := range []struct {
+		perms int32
+		want  string
+	}{
+		{0, ""},
+		{permRead, "r"},
+		{permRead | permWrite | permCreate | permDelete | permAdmin, "cdrwa"},
+		{permAdmin | permCreate, "ca"},
+	} {
+		if got := permString(tc.perms); got != tc.want {
+			t.Errorf("permString(%d) = %q, want %q", tc.perms, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// zkSession 是一个最小化的zookeeper原生协议客户端，只实现exporter需要的操作
+type zkSession struct {
+	conn           net.Conn
+	host           string
+	sessionID      int64
+	timeout        time.Duration
+	requestTimeout time.Duration
+
+	writeMu   sync.Mutex
+	pingMu    sync.Mutex
+	pendingMu sync.Mutex
+	pending   map[int32]chan zkResponse
+	xid       int32
+
+	events    chan zkWatchEvent
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+type zkResponse struct {
+	zxid int64
+	err  error
+	body *juteDecoder
+}
+
+// zkWatchEvent is a WatcherEvent notification sent by zookeeper
+type zkWatchEvent struct {
+	Type  int32
+	State int32
+	Path  string
+}
+
+var errSessionClosed = errors.New("zk: session closed")
+
+const defaultSessionTimeout = 10 * time.Second
+
+// dialSession 连接host并完成握手，sessionTimeout是期望的session超时时间，实际值由服务端协商决定
+func dialSession(host string, sessionTimeout time.Duration) (*zkSession, error) {
+	conn, err := newClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	requestTimeout := time.Duration(config.Timeout) * time.Second
+	s := &zkSession{
+		conn:           conn,
+		host:           host,
+		requestTimeout: requestTimeout,
+		pending:        make(map[int32]chan zkResponse),
+		events:         make(chan zkWatchEvent, 16),
+		closed:         make(chan struct{}),
+	}
+
+	if err := s.handshake(sessionTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go s.readLoop()
+	go s.pingLoop()
+
+	if err := s.authenticate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ConnectRequest: protocolVersion, lastZxidSeen, timeOut, sessionId, passwd, readOnly
+// ConnectResponse: protocolVersion, timeOut, sessionId, passwd, readOnly(可选)
+func (s *zkSession) handshake(sessionTimeout time.Duration) error {
+	s.conn.SetDeadline(time.Now().Add(s.requestTimeout))
+	defer s.conn.SetDeadline(time.Time{})
+
+	enc := &juteEncoder{}
+	enc.writeInt(0)
+	enc.writeLong(0)
+	enc.writeInt(int32(sessionTimeout / time.Millisecond))
+	enc.writeLong(0)
+	enc.writeBuffer(make([]byte, 16))
+	enc.writeBool(true)
+	if err := s.writePacket(enc.bytes()); err != nil {
+		return err
+	}
+
+	packet, err := s.readPacket()
+	if err != nil {
+		return err
+	}
+
+	dec := newJuteDecoder(packet)
+	dec.readInt()
+	timeout := dec.readInt()
+	s.sessionID = dec.readLong()
+	dec.readBuffer()
+	if dec.err != nil {
+		return dec.err
+	}
+	if timeout <= 0 {
+		return errSessionExpired
+	}
+	s.timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (s *zkSession) writePacket(payload []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	buf := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[4:], payload)
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.requestTimeout))
+	_, err := s.conn.Write(buf)
+	return err
+}
+
+func (s *zkSession) readPacket() ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(s.conn, length[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(length[:])
+	if n > 64*1024*1024 {
+		return nil, fmt.Errorf("zk: packet of %d bytes from %s is too large", n, s.host)
+	}
+
+	packet := make([]byte, n)
+	if _, err := io.ReadFull(s.conn, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// readLoop 读取所有响应，按照xid分发给等待的请求，watch通知写入events
+func (s *zkSession) readLoop() {
+	var err error
+	for {
+		var packet []byte
+		packet, err = s.readPacket()
+		if err != nil {
+			break
+		}
+
+		dec := newJuteDecoder(packet)
+		xid := dec.readInt()
+		zxid := dec.readLong()
+		code := dec.readInt()
+		if dec.err != nil {
+			err = dec.err
+			break
+		}
+
+		if xid == xidNotification {
+			event := zkWatchEvent{Type: dec.readInt(), State: dec.readInt(), Path: dec.readString()}
+			select {
+			case s.events <- event:
+			default:
+			}
+			continue
+		}
+
+		resp := zkResponse{zxid: zxid, body: dec}
+		if code != 0 {
+			resp.err = zkError(code)
+		}
+
+		s.pendingMu.Lock()
+		ch, ok := s.pending[xid]
+		delete(s.pending, xid)
+		s.pendingMu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+
+	s.shutdown(err)
+}
+
+// pingLoop 在session超时时间的1/3时发送ping，保证长时间的遍历不会让session过期
+func (s *zkSession) pingLoop() {
+	ticker := time.NewTicker(s.timeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+			s.Ping()
+		}
+	}
+}
+
+func (s *zkSession) shutdown(err error) {
+	s.closeOnce.Do(func() {
+		if err == nil {
+			err = errSessionClosed
+		}
+		s.pendingMu.Lock()
+		s.err = err
+		for xid, ch := range s.pending {
+			ch <- zkResponse{err: err}
+			delete(s.pending, xid)
+		}
+		s.pendingMu.Unlock()
+		close(s.closed)
+		s.conn.Close()
+	})
+}
+
+func (s *zkSession) nextXid() int32 {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	s.xid++
+	if s.xid <= 0 {
+		s.xid = 1
+	}
+	return s.xid
+}
+
+// call 发送一个请求并等待响应，返回的juteDecoder已经读过了ReplyHeader
+func (s *zkSession) call(xid int32, opcode int32, body []byte) (zkResponse, error) {
+	ch := make(chan zkResponse, 1)
+
+	s.pendingMu.Lock()
+	if s.err != nil {
+		s.pendingMu.Unlock()
+		return zkResponse{}, s.err
+	}
+	s.pending[xid] = ch
+	s.pendingMu.Unlock()
+
+	enc := &juteEncoder{}
+	enc.writeInt(xid)
+	enc.writeInt(opcode)
+	enc.buf.Write(body)
+	if err := s.writePacket(enc.bytes()); err != nil {
+		s.shutdown(err)
+		return zkResponse{}, err
+	}
+
+	timer := time.NewTimer(s.requestTimeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		return resp, resp.err
+	case <-timer.C:
+		s.pendingMu.Lock()
+		delete(s.pending, xid)
+		s.pendingMu.Unlock()
+		return zkResponse{}, errOperationTimeout
+	}
+}
+
+func (s *zkSession) request(opcode int32, body []byte) (zkResponse, error) {
+	return s.call(s.nextXid(), opcode, body)
+}
+
+func pathRequest(path string, watch bool) []byte {
+	enc := &juteEncoder{}
+	enc.writeString(path)
+	enc.writeBool(watch)
+	return enc.bytes()
+}
+
+// Ping sends a ping and returns the round trip time
+func (s *zkSession) Ping() (time.Duration, error) {
+	s.pingMu.Lock()
+	defer s.pingMu.Unlock()
+
+	start := time.Now()
+	if _, err := s.call(xidPing, opPing, nil); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// Exists returns the stat of path, or nil if path does not exist
+func (s *zkSession) Exists(path string) (*zkStat, error) {
+	resp, err := s.request(opExists, pathRequest(path, false))
+	if err == errNoNode {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stat := resp.body.readStat()
+	return stat, resp.body.err
+}
+
+// ExistsWatch is Exists leaving a data watch on path, which fires on
+// creation if path does not exist yet
+func (s *zkSession) ExistsWatch(path string) (*zkStat, error) {
+	resp, err := s.request(opExists, pathRequest(path, true))
+	if err == errNoNode {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stat := resp.body.readStat()
+	return stat, resp.body.err
+}
+
+// Sync waits until the server of the session has caught up with the leader on path
+func (s *zkSession) Sync(path string) error {
+	enc := &juteEncoder{}
+	enc.writeString(path)
+	resp, err := s.request(opSync, enc.bytes())
+	if err != nil {
+		return err
+	}
+
+	resp.body.readString()
+	return resp.body.err
+}
+
+// Events returns the watch notifications of the session
+func (s *zkSession) Events() <-chan zkWatchEvent {
+	return s.events
+}
+
+// Stat returns the stat of path, errNoNode if path does not exist
+func (s *zkSession) Stat(path string) (*zkStat, error) {
+	stat, err := s.Exists(path)
+	if err == nil && stat == nil {
+		err = errNoNode
+	}
+	return stat, err
+}
+
+// GetData returns the data and stat of path
+func (s *zkSession) GetData(path string) ([]byte, *zkStat, error) {
+	resp, err := s.request(opGetData, pathRequest(path, false))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := resp.body.readBuffer()
+	stat := resp.body.readStat()
+	return data, stat, resp.body.err
+}
+
+// GetChildren returns the children and stat of path
+func (s *zkSession) GetChildren(path string) ([]string, *zkStat, error) {
+	resp, err := s.request(opGetChildren2, pathRequest(path, false))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	children := resp.body.readStrings()
+	stat := resp.body.readStat()
+	return children, stat, resp.body.err
+}
+
+// GetACL returns the ACL and stat of path
+func (s *zkSession) GetACL(path string) ([]zkACL, *zkStat, error) {
+	enc := &juteEncoder{}
+	enc.writeString(path)
+	resp, err := s.request(opGetACL, enc.bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	acls := resp.body.readACLs()
+	stat := resp.body.readStat()
+	return acls, stat, resp.body.err
+}
+
+// Create creates path with data and returns the created path
+func (s *zkSession) Create(path string, data []byte, acls []zkACL, flags int32) (string, error) {
+	enc := &juteEncoder{}
+	enc.writeString(path)
+	enc.writeBuffer(data)
+	enc.writeACLs(acls)
+	enc.writeInt(flags)
+	resp, err := s.request(opCreate, enc.bytes())
+	if err != nil {
+		return "", err
+	}
+
+	created := resp.body.readString()
+	return created, resp.body.err
+}
+
+// SetData sets the data of path if its version matches, -1 matches any version
+func (s *zkSession) SetData(path string, data []byte, version int32) (*zkStat, error) {
+	enc := &juteEncoder{}
+	enc.writeString(path)
+	enc.writeBuffer(data)
+	enc.writeInt(version)
+	resp, err := s.request(opSetData, enc.bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	stat := resp.body.readStat()
+	return stat, resp.body.err
+}
+
+// Delete deletes path if its version matches, -1 matches any version
+func (s *zkSession) Delete(path string, version int32) error {
+	enc := &juteEncoder{}
+	enc.writeString(path)
+	enc.writeInt(version)
+	_, err := s.request(opDelete, enc.bytes())
+	return err
+}
+
+// Close closes the session on the server and the connection
+func (s *zkSession) Close() {
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+
+	s.request(opCloseSession, nil)
+	s.shutdown(nil)
+}
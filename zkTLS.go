@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// cachedTLSConfig 记录生成tls.Config时各个文件的修改时间，文件变化后重新加载
+type cachedTLSConfig struct {
+	config   *tls.Config
+	modTimes map[string]time.Time
+}
+
+var (
+	tlsConfigsMu sync.Mutex
+	tlsConfigs   = make(map[tlsConfig]*cachedTLSConfig)
+
+	// memberTLSConfigs 是从target的conf得到的成员沿用的target的TLS配置
+	memberTLSConfigsMu sync.Mutex
+	memberTLSConfigs   = make(map[string]tlsConfig)
+)
+
+// tlsConfigFor returns the tls settings of host, tls_targets takes precedence over
+// the settings inherited from the target listing host as a member, and those over tls
+func tlsConfigFor(host string) tlsConfig {
+	if tc, ok := config.TLSTargets[host]; ok {
+		return tc
+	}
+
+	memberTLSConfigsMu.Lock()
+	defer memberTLSConfigsMu.Unlock()
+	if tc, ok := memberTLSConfigs[host]; ok {
+		return tc
+	}
+	return config.TLS
+}
+
+// inheritTLSConfig 让member使用列出它的target的TLS配置，tls_targets中配置过的member不受影响
+func inheritTLSConfig(member string, tc tlsConfig) {
+	if _, ok := config.TLSTargets[member]; ok {
+		return
+	}
+
+	memberTLSConfigsMu.Lock()
+	defer memberTLSConfigsMu.Unlock()
+	if tc == config.TLS {
+		delete(memberTLSConfigs, member)
+		return
+	}
+	memberTLSConfigs[member] = tc
+}
+
+func (tc tlsConfig) files() []string {
+	var files []string
+	for _, f := range []string{tc.CAFile, tc.CertFile, tc.KeyFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func fileModTimes(files []string) (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[f] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (tc tlsConfig) build() (*tls.Config, error) {
+	c := &tls.Config{}
+
+	if tc.MinVersion != "" {
+		v, ok := tlsVersions[tc.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls min_version %q", tc.MinVersion)
+		}
+		c.MinVersion = v
+	}
+
+	if tc.CAFile != "" {
+		ca, err := ioutil.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", tc.CAFile)
+		}
+	}
+
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// loadTLSConfig 返回tc对应的tls.Config，证书轮换后文件的修改时间会变化，此时重新加载
+func loadTLSConfig(tc tlsConfig) (*tls.Config, error) {
+	modTimes, err := fileModTimes(tc.files())
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfigsMu.Lock()
+	defer tlsConfigsMu.Unlock()
+
+	if cached, ok := tlsConfigs[tc]; ok && sameModTimes(cached.modTimes, modTimes) {
+		return cached.config, nil
+	}
+
+	c, err := tc.build()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfigs[tc] = &cachedTLSConfig{config: c, modTimes: modTimes}
+	return c, nil
+}
+
+func sameModTimes(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for f, t := range a {
+		if !b[f].Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialTLS 通过TLS连接secureClientPort，server_name为空时使用host中的主机名校验证书
+func dialTLS(dialer *net.Dialer, host string, addr string, tc tlsConfig) (net.Conn, error) {
+	c, err := loadTLSConfig(tc)
+	if err != nil {
+		return nil, err
+	}
+
+	c = c.Clone()
+	c.ServerName = tc.ServerName
+	if c.ServerName == "" {
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			c.ServerName = name
+		}
+	}
+
+	return tls.DialWithDialer(dialer, "tcp", addr, c)
+}